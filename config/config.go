@@ -0,0 +1,425 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/x-actions/git-mirrors/constants"
+	"github.com/x-actions/git-mirrors/mirrors"
+)
+
+// default values of job, same as the command line flags
+const (
+	defaultAccountType   = constants.AccountTypeUser
+	defaultCloneStyle    = "ssh"
+	defaultCachePath     = "/github/workspace/git-mirrors-cache"
+	defaultTimeout       = "30m"
+	defaultConcurrency   = 1
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = "2s"
+	defaultRetryJitter   = 0.2
+)
+
+// Job is a mirror from src to dst, the fields are same as the command line flags.
+// src_token, dst_token and dst_key are expanded with environment variables, like `${GITEE_TOKEN}`,
+// so the credentials need not to be written in the config file
+type Job struct {
+	Name            string            `json:"name"`
+	Src             string            `json:"src"`
+	SrcToken        string            `json:"src_token"`
+	SrcAPIURL       string            `json:"src_api_url"`
+	SrcUploadURL    string            `json:"src_upload_url"`
+	SrcAccountType  string            `json:"src_account_type"`
+	SrcURLTemplate  string            `json:"src_url_template"`
+	SrcRepos        []string          `json:"src_repos"`
+	Dst             string            `json:"dst"`
+	DstKey          string            `json:"dst_key"`
+	DstToken        string            `json:"dst_token"`
+	DstAPIURL       string            `json:"dst_api_url"`
+	DstUploadURL    string            `json:"dst_upload_url"`
+	DstAccountType  string            `json:"dst_account_type"`
+	DstURLTemplate  string            `json:"dst_url_template"`
+	AccountType     string            `json:"account_type"`
+	CloneStyle      string            `json:"clone_style"`
+	CachePath       string            `json:"cache_path"`
+	BlackList       []string          `json:"black_list"`
+	WhiteList       []string          `json:"white_list"`
+	Mappings        map[string]string `json:"mappings"`
+	ForceUpdate     *bool             `json:"force_update"`
+	Debug           *bool             `json:"debug"`
+	Timeout         string            `json:"timeout"`
+	Concurrency     int               `json:"concurrency"`
+	APIRate         float64           `json:"api_rate"`
+	DryRun          *bool             `json:"dry_run"`
+	SkipForks       *bool             `json:"skip_forks"`
+	SkipArchived    *bool             `json:"skip_archived"`
+	Visibility      string            `json:"visibility"`
+	RequireTopics   []string          `json:"require_topics"`
+	Languages       []string          `json:"languages"`
+	PushedSince     string            `json:"pushed_since"`
+	NameRules       []string          `json:"name_rules"`
+	IncludeBranches []string          `json:"include_branches"`
+	ExcludeBranches []string          `json:"exclude_branches"`
+	IncludeTags     []string          `json:"include_tags"`
+	ExcludeTags     []string          `json:"exclude_tags"`
+	PruneExcluded   *bool             `json:"prune_excluded"`
+	RefRenames      []string          `json:"ref_renames"`
+	ExtraRefs       []string          `json:"extra_refs"`
+	GitBackend      string            `json:"git_backend"`
+	StateStore      string            `json:"state_store"`
+	RetryAttempts   int               `json:"retry_attempts"`
+	RetryBackoff    string            `json:"retry_backoff"`
+	RetryJitter     *float64          `json:"retry_jitter"`
+	OrphanPolicy    string            `json:"orphan_policy"`
+	ConfirmDelete   *bool             `json:"confirm_delete"`
+
+	srcGit  string
+	srcOrg  string
+	dstGit  string
+	dstOrg  string
+	timeout time.Duration
+	filter  *mirrors.RepoFilter
+	refs    *mirrors.RefFilter
+	retry   *mirrors.RetryPolicy
+}
+
+// Config declare many jobs, the unset fields of job are taken from Defaults
+type Config struct {
+	Parallel int    `json:"parallel"` // the number of jobs run in parallel, default is 1, run in sequence
+	Defaults *Job   `json:"defaults"`
+	Jobs     []*Job `json:"jobs"`
+}
+
+// decode parse the config by the extension of path, `.yaml`/`.yml` is yaml, `.toml` is toml, others are json.
+// yaml and toml are converted to json, so all the formats share the field names and reject the unknown fields
+func decode(path string, content []byte) (*Config, error) {
+	var raw map[string]interface{}
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err = yaml.Unmarshal(content, &raw); err != nil {
+			return nil, err
+		}
+		content, err = json.Marshal(raw)
+	case ".toml":
+		if err = toml.Unmarshal(content, &raw); err != nil {
+			return nil, err
+		}
+		content, err = json.Marshal(raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &Config{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// Load read the config file in json, yaml or toml, merge the defaults to jobs and validate all of them
+func Load(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s err: %s", path, err.Error())
+	}
+
+	conf, err := decode(path, content)
+	if err != nil {
+		return nil, fmt.Errorf("decode config %s err: %s", path, err.Error())
+	}
+	if len(conf.Jobs) == 0 {
+		return nil, fmt.Errorf("config %s has no jobs", path)
+	}
+	if conf.Parallel == 0 {
+		conf.Parallel = 1
+	} else if conf.Parallel < 0 {
+		return nil, fmt.Errorf("parallel must be greater than 0, got %d", conf.Parallel)
+	}
+
+	var errs []error
+	names := make(map[string]int, len(conf.Jobs))
+	caches := make(map[string]int, len(conf.Jobs))
+	for i, job := range conf.Jobs {
+		if conf.Defaults != nil {
+			job.merge(conf.Defaults)
+		}
+		job.SrcToken = os.ExpandEnv(job.SrcToken)
+		job.DstToken = os.ExpandEnv(job.DstToken)
+		job.DstKey = os.ExpandEnv(job.DstKey)
+
+		if err := job.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("job %d(%s): %s", i+1, job.Name, err.Error()))
+			continue
+		}
+		if j, ok := names[job.Name]; ok {
+			errs = append(errs, fmt.Errorf("job %d(%s): duplicate name with job %d", i+1, job.Name, j+1))
+		}
+		names[job.Name] = i
+
+		// the parallel jobs with same source and cache path would fetch into the same cache repos at the same time
+		cache := job.CachePath + "|" + job.Src
+		if conf.Parallel > 1 {
+			if j, ok := caches[cache]; ok {
+				errs = append(errs, fmt.Errorf("job %d(%s): share source %s and cache path %s with job %d, can not run in parallel",
+					i+1, job.Name, job.Src, job.CachePath, j+1))
+			}
+			caches[cache] = i
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return conf, nil
+}
+
+// merge set the unset fields of job to the value of defaults. the lists and mappings set to empty,
+// like `[]` and `{}`, and the switches set to false are kept, but the empty strings and zero numbers
+// are unset, they are always taken from defaults
+func (j *Job) merge(defaults *Job) {
+	jv := reflect.ValueOf(j).Elem()
+	dv := reflect.ValueOf(defaults).Elem()
+	for i := 0; i < jv.NumField(); i++ {
+		field := jv.Field(i)
+		if field.CanSet() && field.IsZero() {
+			field.Set(dv.Field(i))
+		}
+	}
+}
+
+// parseGitSource parse `github/xiexianbin` to git and org, gitlab org name may contain sub groups, like `gitlab/group/sub`
+func parseGitSource(str string) (string, string, bool) {
+	if gitInfo := strings.SplitN(str, "/", 2); len(gitInfo) == 2 && gitInfo[1] != "" {
+		for _, g := range constants.SupportGit {
+			if g == gitInfo[0] {
+				return gitInfo[0], gitInfo[1], true
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+// Validate check the job and fill the default values
+func (j *Job) Validate() error {
+	var ok bool
+	if j.srcGit, j.srcOrg, ok = parseGitSource(j.Src); !ok {
+		return fmt.Errorf("un-support git source %s", j.Src)
+	}
+	if j.dstGit, j.dstOrg, ok = parseGitSource(j.Dst); !ok {
+		return fmt.Errorf("un-support git destination %s", j.Dst)
+	}
+	if j.Name == "" {
+		j.Name = j.Src + "=>" + j.Dst
+	}
+
+	// account type
+	if j.AccountType == "" {
+		j.AccountType = defaultAccountType
+	}
+	if j.SrcAccountType == "" {
+		j.SrcAccountType = j.AccountType
+	}
+	if j.DstAccountType == "" {
+		j.DstAccountType = j.AccountType
+	}
+	for _, t := range []string{j.SrcAccountType, j.DstAccountType} {
+		if t != constants.AccountTypeUser && t != constants.AccountTypeOrg {
+			return fmt.Errorf("un-support account-type %s", t)
+		}
+	}
+
+	if _, err := mirrors.NewNameMatcher(j.BlackList); err != nil {
+		return fmt.Errorf("black-list: %s", err.Error())
+	}
+	if _, err := mirrors.NewNameMatcher(j.WhiteList); err != nil {
+		return fmt.Errorf("white-list: %s", err.Error())
+	}
+
+	if _, err := mirrors.NewNameRules(j.NameRules); err != nil {
+		return err
+	}
+
+	// repo filter
+	if j.Visibility == "" {
+		j.Visibility = constants.VisibilityAll
+	}
+	pushedSince, err := mirrors.ParseSince(j.PushedSince)
+	if err != nil {
+		return fmt.Errorf("parse pushed-since: %s", err.Error())
+	}
+	j.filter = &mirrors.RepoFilter{
+		SkipForks:     j.SkipForks != nil && *j.SkipForks,
+		SkipArchived:  j.SkipArchived != nil && *j.SkipArchived,
+		Visibility:    j.Visibility,
+		RequireTopics: j.RequireTopics,
+		Languages:     j.Languages,
+		PushedSince:   pushedSince,
+	}
+	if err := j.filter.Validate(); err != nil {
+		return err
+	}
+
+	// ref filter
+	j.refs = &mirrors.RefFilter{
+		IncludeBranches: j.IncludeBranches,
+		ExcludeBranches: j.ExcludeBranches,
+		IncludeTags:     j.IncludeTags,
+		ExcludeTags:     j.ExcludeTags,
+		PruneExcluded:   j.PruneExcluded != nil && *j.PruneExcluded,
+	}
+	if err := j.refs.Validate(); err != nil {
+		return err
+	}
+	if _, err := mirrors.NewRefRenames(j.RefRenames); err != nil {
+		return err
+	}
+	if _, err := mirrors.NewExtraRefSpecs(j.ExtraRefs, ""); err != nil {
+		return err
+	}
+
+	if j.srcGit == constants.GIT && j.SrcURLTemplate == "" {
+		return fmt.Errorf("src-url-template is required when src is %s", j.Src)
+	}
+	if j.dstGit == constants.GIT && j.DstURLTemplate == "" {
+		return fmt.Errorf("dst-url-template is required when dst is %s", j.Dst)
+	}
+
+	if j.Concurrency == 0 {
+		j.Concurrency = defaultConcurrency
+	} else if j.Concurrency < 0 {
+		return fmt.Errorf("concurrency must be greater than 0, got %d", j.Concurrency)
+	}
+	if j.APIRate < 0 {
+		return fmt.Errorf("api-rate must not be negative, got %f", j.APIRate)
+	}
+
+	// parse timeout
+	if j.Timeout == "" {
+		j.Timeout = defaultTimeout
+	}
+	j.timeout, err = time.ParseDuration(j.Timeout)
+	if err != nil {
+		return fmt.Errorf("parse timeout %s err: %s", j.Timeout, err.Error())
+	}
+
+	if j.GitBackend == "" {
+		j.GitBackend = constants.GitBackendGoGit
+	} else if j.GitBackend != constants.GitBackendGoGit && j.GitBackend != constants.GitBackendCLI {
+		return fmt.Errorf("un-support git-backend %s", j.GitBackend)
+	}
+	switch j.StateStore {
+	case "":
+		j.StateStore = constants.StateStoreJSON
+	case constants.StateStoreJSON, constants.StateStoreKV, constants.StateStoreNone:
+	default:
+		return fmt.Errorf("un-support state-store %s", j.StateStore)
+	}
+
+	// parse retry policy
+	if j.RetryAttempts == 0 {
+		j.RetryAttempts = defaultRetryAttempts
+	} else if j.RetryAttempts < 0 {
+		return fmt.Errorf("retry-attempts must be greater than 0, got %d", j.RetryAttempts)
+	}
+	if j.RetryBackoff == "" {
+		j.RetryBackoff = defaultRetryBackoff
+	}
+	if j.RetryJitter == nil {
+		jitter := defaultRetryJitter
+		j.RetryJitter = &jitter
+	}
+	j.retry = &mirrors.RetryPolicy{MaxAttempts: j.RetryAttempts, Jitter: *j.RetryJitter}
+	if j.retry.Backoff, err = time.ParseDuration(j.RetryBackoff); err != nil {
+		return fmt.Errorf("parse retry-backoff %s err: %s", j.RetryBackoff, err.Error())
+	}
+	if err = j.retry.Validate(); err != nil {
+		return err
+	}
+
+	switch j.OrphanPolicy {
+	case "":
+		j.OrphanPolicy = constants.OrphanKeep
+	case constants.OrphanKeep, constants.OrphanArchive, constants.OrphanMakePrivate:
+	case constants.OrphanDelete:
+		// the deleted repos can not be restored, dry-run only prints them
+		if (j.ConfirmDelete == nil || !*j.ConfirmDelete) && (j.DryRun == nil || !*j.DryRun) {
+			return fmt.Errorf("orphan-policy %s can not be undone, confirm it by confirm-delete", j.OrphanPolicy)
+		}
+	default:
+		return fmt.Errorf("un-support orphan-policy %s", j.OrphanPolicy)
+	}
+
+	if j.CloneStyle == "" {
+		j.CloneStyle = defaultCloneStyle
+	}
+	if j.CachePath == "" {
+		j.CachePath = defaultCachePath
+	}
+	if j.Mappings == nil {
+		j.Mappings = map[string]string{}
+	}
+
+	return nil
+}
+
+// NewMirror build the Mirror of the validated job
+func (j *Job) NewMirror() *mirrors.Mirror {
+	isTrue := func(b *bool) bool {
+		return b != nil && *b
+	}
+
+	mirror := mirrors.New(j.srcGit, j.srcOrg, j.SrcToken, j.dstGit, j.dstOrg, j.DstKey, j.DstToken,
+		j.SrcAccountType, j.DstAccountType, j.CloneStyle, j.CachePath, j.BlackList, j.WhiteList,
+		isTrue(j.ForceUpdate), isTrue(j.Debug), j.timeout, j.Mappings)
+	mirror.SrcAPIURL = j.SrcAPIURL
+	mirror.SrcUploadURL = j.SrcUploadURL
+	mirror.DstAPIURL = j.DstAPIURL
+	mirror.DstUploadURL = j.DstUploadURL
+	mirror.SrcURLTemplate = j.SrcURLTemplate
+	mirror.SrcRepos = j.SrcRepos
+	mirror.DstURLTemplate = j.DstURLTemplate
+	mirror.Concurrency = j.Concurrency
+	mirror.APIRate = j.APIRate
+	mirror.DryRun = isTrue(j.DryRun)
+	mirror.Filter = j.filter
+	mirror.NameRules = j.NameRules
+	mirror.RefFilter = j.refs
+	mirror.RefRenames = j.RefRenames
+	mirror.ExtraRefs = j.ExtraRefs
+	mirror.GitBackend = j.GitBackend
+	mirror.StateStore = j.StateStore
+	mirror.Retry = j.retry
+	mirror.OrphanPolicy = j.OrphanPolicy
+	mirror.ConfirmDelete = isTrue(j.ConfirmDelete)
+
+	return mirror
+}
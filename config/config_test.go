@@ -0,0 +1,190 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	return writeConfigFile(t, "config.json", content)
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("TEST_GITEE_TOKEN", "secret")
+	path := writeConfig(t, `{
+  "parallel": 2,
+  "defaults": {
+    "dst_token": "${TEST_GITEE_TOKEN}",
+    "account_type": "org",
+    "timeout": "10m",
+    "black_list": ["tmp"],
+    "force_update": true
+  },
+  "jobs": [
+    {"src": "github/org1", "dst": "gitee/org1"},
+    {"name": "org2", "src": "github/org2", "dst": "gitee/org2", "timeout": "1h", "force_update": false,
+     "mappings": {"a": "b"}}
+  ]
+}`)
+
+	conf, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Parallel != 2 || len(conf.Jobs) != 2 {
+		t.Fatalf("unexpected config %+v", conf)
+	}
+
+	job1, job2 := conf.Jobs[0], conf.Jobs[1]
+	if job1.Name != "github/org1=>gitee/org1" || job1.DstToken != "secret" || job1.timeout != 10*time.Minute {
+		t.Errorf("unexpected job1 %+v", job1)
+	}
+	if job1.SrcAccountType != "org" || job1.CloneStyle != defaultCloneStyle || len(job1.BlackList) != 1 {
+		t.Errorf("expect job1 use defaults, got %+v", job1)
+	}
+	if job2.timeout != time.Hour || *job2.ForceUpdate {
+		t.Errorf("expect job2 override defaults, got %+v", job2)
+	}
+
+	m := job2.NewMirror()
+	if m.SrcGit != "github" || m.DstOrg != "org2" || m.ForceUpdate || m.Mappings["a"] != "b" || m.Concurrency != 1 {
+		t.Errorf("unexpected mirror %+v", m)
+	}
+}
+
+func TestLoad_Formats(t *testing.T) {
+	t.Setenv("TEST_GITEE_TOKEN", "secret")
+	contents := map[string]string{
+		"config.json": `{
+  "defaults": {"dst_token": "${TEST_GITEE_TOKEN}", "account_type": "org", "black_list": ["tmp"]},
+  "jobs": [
+    {"src": "github/org1", "dst": "gitee/org1"},
+    {"src": "github/org2", "dst": "gitee/org2", "black_list": [], "mappings": {"a": "b"}, "retry_jitter": 0.5}
+  ]
+}`,
+		"config.yaml": `
+defaults:
+  dst_token: ${TEST_GITEE_TOKEN}
+  account_type: org
+  black_list: [tmp]
+jobs:
+  - src: github/org1
+    dst: gitee/org1
+  - src: github/org2
+    dst: gitee/org2
+    black_list: []
+    mappings:
+      a: b
+    retry_jitter: 0.5
+`,
+		"config.toml": `
+[defaults]
+dst_token = "${TEST_GITEE_TOKEN}"
+account_type = "org"
+black_list = ["tmp"]
+
+[[jobs]]
+src = "github/org1"
+dst = "gitee/org1"
+
+[[jobs]]
+src = "github/org2"
+dst = "gitee/org2"
+black_list = []
+mappings = {a = "b"}
+retry_jitter = 0.5
+`,
+	}
+	contents["config.yml"] = contents["config.yaml"]
+
+	for name, content := range contents {
+		t.Run(name, func(t *testing.T) {
+			conf, err := Load(writeConfigFile(t, name, content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(conf.Jobs) != 2 {
+				t.Fatalf("unexpected config %+v", conf)
+			}
+			job1, job2 := conf.Jobs[0], conf.Jobs[1]
+			if job1.DstToken != "secret" || job1.SrcAccountType != "org" || len(job1.BlackList) != 1 {
+				t.Errorf("expect job1 use defaults, got %+v", job1)
+			}
+			// the empty list overrides defaults
+			if len(job2.BlackList) != 0 || job2.Mappings["a"] != "b" || *job2.RetryJitter != 0.5 {
+				t.Errorf("expect job2 override defaults, got %+v", job2)
+			}
+		})
+	}
+
+	if _, err := Load(writeConfigFile(t, "config.yaml", "jobs:\n  - src: github/org\n    dst: gitee/org\n    unknown: 1\n")); err == nil {
+		t.Error("expect unknown field err of yaml")
+	}
+	if _, err := Load(writeConfigFile(t, "config.toml", "[[jobs]]\nsrc = github/org\n")); err == nil {
+		t.Error("expect invalid toml err")
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	path := writeConfig(t, `{
+  "jobs": [
+    {"src": "svn/org", "dst": "gitee/org"},
+    {"src": "github/org", "dst": "gitee/org", "timeout": "1x"},
+    {"src": "git/org", "dst": "gitee/org"},
+    {"src": "github/org", "dst": "gitee/org", "black_list": ["re:("]},
+    {"src": "github/org", "dst": "gitee/org", "visibility": "internal", "pushed_since": "3x"},
+    {"src": "github/org", "dst": "gitee/org", "include_tags": ["v[1"]},
+    {"src": "github/org", "dst": "gitee/org", "ref_renames": ["refs/heads/*:master"]},
+    {"src": "github/org", "dst": "gitee/org", "git_backend": "jgit"},
+    {"src": "github/org", "dst": "gitee/org", "state_store": "bolt"},
+    {"src": "github/org", "dst": "gitee/org", "retry_backoff": "1x", "retry_jitter": 2},
+    {"src": "github/org", "dst": "gitee/org", "orphan_policy": "delete"}
+  ]
+}`)
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expect invalid config err")
+	}
+	for _, msg := range []string{"job 1", "job 2", "job 3", "job 4", "job 5", "job 6", "job 7", "job 8", "job 9", "job 10", "job 11"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expect all invalid jobs are reported, got %s", err)
+		}
+	}
+
+	if _, err := Load(writeConfig(t, `{"jobs": [{"src": "github/org", "dst": "gitee/org", "unknown": 1}]}`)); err == nil {
+		t.Error("expect unknown field err")
+	}
+	if _, err := Load(writeConfig(t, `{"parallel": 2, "jobs": [{"src": "github/org", "dst": "gitee/org"},
+		{"src": "github/org", "dst": "gitlab/org"}]}`)); err == nil {
+		t.Error("expect shared cache err of parallel jobs")
+	}
+	if _, err := Load(writeConfig(t, `{"jobs": []}`)); err == nil {
+		t.Error("expect no jobs err")
+	}
+}
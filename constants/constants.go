@@ -3,11 +3,68 @@ package constants
 const (
 	GITHUB = "github"
 	GITEE  = "gitee"
+	GITLAB = "gitlab"
+
+	// GITEA FORGEJO CODEBERG use the same gitea v1 api
+	GITEA    = "gitea"
+	FORGEJO  = "forgejo"
+	CODEBERG = "codeberg"
+
+	// BITBUCKET is bitbucket cloud, BITBUCKETSERVER is bitbucket data center
+	BITBUCKET       = "bitbucket"
+	BITBUCKETSERVER = "bitbucket-server"
+
+	// GIT is a plain git server without API, like gitolite, a plain ssh host or a directory
+	GIT = "git"
+
+	// FILE is a local directory of bare repositories, like `file/path/to/backup`
+	FILE = "file"
 )
 
-var SupportGit = []string{GITHUB, GITEE}
+var SupportGit = []string{GITHUB, GITEE, GITLAB, GITEA, FORGEJO, CODEBERG, BITBUCKET, BITBUCKETSERVER, GIT, FILE}
 
 const (
 	AccountTypeUser = "user"
 	AccountTypeOrg  = "org"
 )
+
+// fail-on policy, when to exit with non-zero code
+const (
+	FailOnAny  = "any"
+	FailOnAll  = "all"
+	FailOnNone = "none"
+)
+
+// report file formats
+const (
+	ReportFormatJSON  = "json"
+	ReportFormatJUnit = "junit"
+)
+
+// repo visibility
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+	VisibilityAll     = "all"
+)
+
+// git backends, the implementation of git operations
+const (
+	GitBackendGoGit = "go-git"
+	GitBackendCLI   = "cli"
+)
+
+// state stores, persist the sync state of repos in cache path
+const (
+	StateStoreJSON = "json"
+	StateStoreKV   = "kv"
+	StateStoreNone = "none"
+)
+
+// orphan policies, the action on destination repos which are not mirrored from any source repo
+const (
+	OrphanKeep        = "keep"
+	OrphanArchive     = "archive"
+	OrphanMakePrivate = "make-private"
+	OrphanDelete      = "delete"
+)
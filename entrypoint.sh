@@ -17,6 +17,41 @@ else
   FORCE_UPDATE="false"
 fi
 
+SKIP_FORKS="${INPUT_SKIP_FORKS}"
+if [[ X"$SKIP_FORKS" == X"true" ]]; then
+  SKIP_FORKS="true"
+else
+  SKIP_FORKS="false"
+fi
+
+SKIP_ARCHIVED="${INPUT_SKIP_ARCHIVED}"
+if [[ X"$SKIP_ARCHIVED" == X"true" ]]; then
+  SKIP_ARCHIVED="true"
+else
+  SKIP_ARCHIVED="false"
+fi
+
+PRUNE_EXCLUDED="${INPUT_PRUNE_EXCLUDED}"
+if [[ X"$PRUNE_EXCLUDED" == X"true" ]]; then
+  PRUNE_EXCLUDED="true"
+else
+  PRUNE_EXCLUDED="false"
+fi
+
+DRY_RUN="${INPUT_DRY_RUN}"
+if [[ X"$DRY_RUN" == X"true" ]]; then
+  DRY_RUN="true"
+else
+  DRY_RUN="false"
+fi
+
+CONFIRM_DELETE="${INPUT_CONFIRM_DELETE}"
+if [[ X"$CONFIRM_DELETE" == X"true" ]]; then
+  CONFIRM_DELETE="true"
+else
+  CONFIRM_DELETE="false"
+fi
+
 echo "## Check User ##################"
 whoami
 
@@ -60,9 +95,13 @@ echo "## begin sync ##################"
 git-mirrors \
   --src "${INPUT_SRC}" \
   --src-token "${INPUT_SRC_TOKEN}" \
+  --src-api-url "${INPUT_SRC_API_URL}" \
+  --src-upload-url "${INPUT_SRC_UPLOAD_URL}" \
   --dst "${INPUT_DST}" \
   --dst-key "${DST_KEY}" \
   --dst-token "${INPUT_DST_TOKEN}" \
+  --dst-api-url "${INPUT_DST_API_URL}" \
+  --dst-upload-url "${INPUT_DST_UPLOAD_URL}" \
   --account-type "${INPUT_ACCOUNT_TYPE}" \
   --clone-style "${INPUT_CLONE_STYLE}" \
   --cache-path "${INPUT_CACHE_PATH}" \
@@ -71,6 +110,37 @@ git-mirrors \
   --force-update="${FORCE_UPDATE}" \
   --debug="${DEBUG}" \
   --timeout "${INPUT_TIMEOUT}" \
-  --mappings "${INPUT_MAPPINGS}"
+  --mappings "${INPUT_MAPPINGS}" \
+  --name-rules "${INPUT_NAME_RULES}" \
+  --src-url-template "${INPUT_SRC_URL_TEMPLATE}" \
+  --dst-url-template "${INPUT_DST_URL_TEMPLATE}" \
+  --src-repos "${INPUT_SRC_REPOS}" \
+  --concurrency "${INPUT_CONCURRENCY:-1}" \
+  --api-rate "${INPUT_API_RATE:-0}" \
+  --skip-forks="${SKIP_FORKS}" \
+  --skip-archived="${SKIP_ARCHIVED}" \
+  --visibility "${INPUT_VISIBILITY:-all}" \
+  --require-topic "${INPUT_REQUIRE_TOPIC}" \
+  --language "${INPUT_LANGUAGE}" \
+  --pushed-since "${INPUT_PUSHED_SINCE}" \
+  --include-branches "${INPUT_INCLUDE_BRANCHES}" \
+  --exclude-branches "${INPUT_EXCLUDE_BRANCHES}" \
+  --include-tags "${INPUT_INCLUDE_TAGS}" \
+  --exclude-tags "${INPUT_EXCLUDE_TAGS}" \
+  --prune-excluded="${PRUNE_EXCLUDED}" \
+  --ref-renames "${INPUT_REF_RENAMES}" \
+  --extra-refs "${INPUT_EXTRA_REFS}" \
+  --git-backend "${INPUT_GIT_BACKEND:-go-git}" \
+  --state-store "${INPUT_STATE_STORE:-json}" \
+  --retry-attempts "${INPUT_RETRY_ATTEMPTS:-3}" \
+  --retry-backoff "${INPUT_RETRY_BACKOFF:-2s}" \
+  --retry-jitter "${INPUT_RETRY_JITTER:-0.2}" \
+  --orphan-policy "${INPUT_ORPHAN_POLICY:-keep}" \
+  --confirm-delete="${CONFIRM_DELETE}" \
+  --config "${INPUT_CONFIG}" \
+  --dry-run="${DRY_RUN}" \
+  --report-file "${INPUT_REPORT_FILE}" \
+  --report-format "${INPUT_REPORT_FORMAT:-json}" \
+  --fail-on "${INPUT_FAIL_ON:-any}"
 
 echo "## Done. ##################"
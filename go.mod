@@ -4,11 +4,14 @@ go 1.20
 
 require (
 	gitee.com/openeuler/go-gitee v0.0.0-20220323055843-285220630cbc
+	github.com/BurntSushi/toml v1.3.2
 	github.com/antihax/optional v1.0.0
 	github.com/go-git/go-git/v5 v5.4.2
 	github.com/google/go-github v17.0.0+incompatible
 	github.com/xiexianbin/golib v0.1.1
+	go.etcd.io/bbolt v1.3.8
 	golang.org/x/oauth2 v0.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
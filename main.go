@@ -19,10 +19,12 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/xiexianbin/golib/logger"
 
+	"github.com/x-actions/git-mirrors/config"
 	"github.com/x-actions/git-mirrors/constants"
 	"github.com/x-actions/git-mirrors/mirrors"
 )
@@ -30,9 +32,13 @@ import (
 var (
 	src            string
 	srcToken       string
+	srcAPIURL      string
+	srcUploadURL   string
 	dst            string
 	dstKey         string
 	dstToken       string
+	dstAPIURL      string
+	dstUploadURL   string
 	accountType    string
 	srcAccountType string
 	dstAccountType string
@@ -45,39 +51,110 @@ var (
 	forceUpdate    bool
 	debug          bool
 	timeoutStr     string
-	timeout        time.Duration
 	mappingsStr    string
 	mappings       map[string]string
+	srcURLTemplate string
+	dstURLTemplate string
+	srcReposStr    string
+	srcRepos       []string
+	concurrency    int
+	apiRate        float64
+	failOn         string
+	reportFile     string
+	reportFormat   string
+	dryRun         bool
+	configPath     string
+	skipForks      bool
+	skipArchived   bool
+	visibility     string
+	requireTopic   string
+	language       string
+	pushedSince    string
+	nameRulesStr   string
+	includeBranch  string
+	excludeBranch  string
+	includeTag     string
+	excludeTag     string
+	pruneExcluded  bool
+	refRenamesStr  string
+	extraRefsStr   string
+	gitBackend     string
+	stateStore     string
+	retryAttempts  int
+	retryBackoff   string
+	retryJitter    float64
+	orphanPolicy   string
+	confirmDelete  bool
 
 	help        bool
 	versionShow bool
 	verbose     bool
 )
 
+// exit codes
+const (
+	exitError          = 1 // invalid params
+	exitTotalFailure   = 2 // all attempted repos failed
+	exitPartialFailure = 3 // some repos failed
+)
+
 var (
-	srcGit string
-	srcOrg string
-	dstGit string
-	dstOrg string
+	parallel int
+	jobs     []*config.Job
 )
 
 func init() {
 	flag.StringVar(&src, "src", "", "Source name. Such as `github/xiexianbin`")
 	flag.StringVar(&srcToken, "src-token", "", "The app token which is used to list repo in source hub")
+	flag.StringVar(&srcAPIURL, "src-api-url", "", "The API base url of source hub, for self-hosted git service. Such as `https://github.example.com/api/v3`")
+	flag.StringVar(&srcUploadURL, "src-upload-url", "", "The API upload url of source Github Enterprise Server, default is derived from src-api-url")
 	flag.StringVar(&dst, "dst", "", "Destination name. Such as `gitee/xiexianbin`")
 	flag.StringVar(&dstKey, "dst-key", "", "The private SSH key which is used to to push code in destination hub")
 	flag.StringVar(&dstToken, "dst-token", "", "The app token which is used to create repo in destination hub")
+	flag.StringVar(&dstAPIURL, "dst-api-url", "", "The API base url of destination hub, for self-hosted git service")
+	flag.StringVar(&dstUploadURL, "dst-upload-url", "", "The API upload url of destination Github Enterprise Server, default is derived from dst-api-url")
 	flag.StringVar(&accountType, "account-type", "user", "The account type. Such as org, user")
 	flag.StringVar(&srcAccountType, "src-account-type", "", "The src account type. Such as org, user")
 	flag.StringVar(&dstAccountType, "dst-account-type", "", "The dst account type. Such as org, user")
 	flag.StringVar(&cloneStyle, "clone-style", "ssh", "The git clone style, https or ssh")
 	flag.StringVar(&cachePath, "cache-path", "/github/workspace/git-mirrors-cache", "The path to cache the source repos code")
-	flag.StringVar(&blackListStr, "black-list", "", "Height priority, the back list of mirror repo. like 'repo1,repo2,repo3'")
-	flag.StringVar(&whiteListStr, "white-list", "", "Low priority, the white list of mirror repo. like 'repo1,repo2,repo3'")
+	flag.StringVar(&blackListStr, "black-list", "", "Height priority, the back list of mirror repo. like 'repo1,repo2,repo3', support glob like '*-archive' and regex like 're:^svc-.*'")
+	flag.StringVar(&whiteListStr, "white-list", "", "Low priority, the white list of mirror repo. like 'repo1,repo2,repo3', support glob like '*-archive' and regex like 're:^svc-.*'")
 	flag.BoolVar(&forceUpdate, "force-update", false, "Force to update the destination repo, use '-f' flag do 'git push'")
 	flag.BoolVar(&debug, "debug", false, "Enable the debug flag to show detail log")
 	flag.StringVar(&timeoutStr, "timeout", "30m", "Set the timeout for every git command, eg. '600s'=>600s, '30m'=>30 minute, '2h'=>2 hours")
 	flag.StringVar(&mappingsStr, "mappings", "", "The source repos mappings, such as 'A=>B, C=>CC', source repo name would be mapped follow the rule: A to B, C to CC. Mapping is not transitive")
+	flag.StringVar(&nameRulesStr, "name-rules", "", "The ordered rules to transform destination repo name after mappings, separated by ';', such as 'lower;replace:.=>-;re:^team-(.*)$=>$1;gh-{name}'")
+	flag.StringVar(&srcURLTemplate, "src-url-template", "", "The repo url template when src is `git/<org>`, such as 'ssh://git@host/{org}/{name}.git'")
+	flag.StringVar(&dstURLTemplate, "dst-url-template", "", "The repo url template when dst is `git/<org>`, such as 'ssh://git@host/{org}/{name}.git'")
+	flag.StringVar(&srcReposStr, "src-repos", "", "The repo list when src is `git/<org>`, like 'repo1,repo2,repo3', or '@path' to read from file, one repo per line")
+	flag.IntVar(&concurrency, "concurrency", 1, "The number of repos mirrored in parallel")
+	flag.Float64Var(&apiRate, "api-rate", 0, "The max API requests per second of source and destination hub, shared by all workers, 0 is no limit")
+	flag.BoolVar(&skipForks, "skip-forks", false, "Skip the forked source repos")
+	flag.BoolVar(&skipArchived, "skip-archived", false, "Skip the archived source repos")
+	flag.StringVar(&visibility, "visibility", constants.VisibilityAll, "Only mirror the source repos with visibility, public, private or all")
+	flag.StringVar(&requireTopic, "require-topic", "", "Only mirror the source repos with all the topics, like 'mirror,backup'")
+	flag.StringVar(&language, "language", "", "Only mirror the source repos in one of the languages, like 'Go,Python'")
+	flag.StringVar(&pushedSince, "pushed-since", "", "Only mirror the source repos pushed in the duration, like '90d', '2w', '36h'")
+	flag.StringVar(&includeBranch, "include-branches", "", "Only push the branches match the glob patterns, like 'main,release/*', default is all")
+	flag.StringVar(&excludeBranch, "exclude-branches", "", "Do not push the branches match the glob patterns, like 'dependabot/*'")
+	flag.StringVar(&includeTag, "include-tags", "", "Only push the tags match the glob patterns, like 'v*', default is all")
+	flag.StringVar(&excludeTag, "exclude-tags", "", "Do not push the tags match the glob patterns, like '*-rc*'")
+	flag.BoolVar(&pruneExcluded, "prune-excluded", false, "Delete the excluded branches and tags in destination, by default they are left untouched")
+	flag.StringVar(&refRenamesStr, "ref-renames", "", "The ordered rules to rename refs in destination, like 'refs/heads/main:refs/heads/master,refs/tags/*:refs/tags/upstream/*'")
+	flag.StringVar(&extraRefsStr, "extra-refs", "", "The extra ref namespaces to mirror besides branches and tags, like 'refs/notes/*,refs/pull/*/head,refs/changes/*', the namespaces rejected by destination are skipped")
+	flag.StringVar(&gitBackend, "git-backend", constants.GitBackendGoGit, "The backend of git operations, go-git or cli(the git binary, supports protocol v2 and faster on large repos)")
+	flag.StringVar(&stateStore, "state-store", constants.StateStoreJSON, "The store of sync state in cache path, json, kv(embedded bbolt database, saved after every repo) or none, see the status command")
+	flag.IntVar(&retryAttempts, "retry-attempts", 3, "The max attempts of git clone/fetch/push and API calls, the transient failures like timeouts, 5xx, 429 and connection resets are retried, 1 is no retry")
+	flag.StringVar(&retryBackoff, "retry-backoff", "2s", "The delay before the first retry, doubled for every retry")
+	flag.Float64Var(&retryJitter, "retry-jitter", 0.2, "The random factor of retry delay in [0, 1], 0.2 means the delay is ±20%")
+	flag.StringVar(&orphanPolicy, "orphan-policy", constants.OrphanKeep, "The action on destination repos whose source is gone, keep, archive, make-private or delete, gitee and bitbucket cloud can not archive")
+	flag.BoolVar(&confirmDelete, "confirm-delete", false, "Confirm the orphan policy delete, the deleted repos can not be restored")
+	flag.BoolVar(&dryRun, "dry-run", false, "Only print the plan of repos to create/update and refs to push/delete, without any change")
+	flag.StringVar(&reportFile, "report-file", "", "Write the mirror report of every repo to the file")
+	flag.StringVar(&reportFormat, "report-format", constants.ReportFormatJSON, "The report file format, json or junit")
+	flag.StringVar(&configPath, "config", "", "The config file declares many jobs with shared defaults, json, yaml(.yaml/.yml) or toml(.toml) by the extension, the src/dst flags are ignored")
+	flag.StringVar(&failOn, "fail-on", constants.FailOnAny, "Exit with non-zero code when any, all or none of the repos failed to mirror")
 
 	flag.BoolVar(&help, "h", false, "print this help")
 	flag.BoolVar(&versionShow, "v", false, "show version")
@@ -87,12 +164,32 @@ func init() {
 
 	flag.Usage = func() {
 		fmt.Println("Run: git-mirrors -h\n\n" +
-			"Usage:")
+			"Usage:\n" +
+			"  git-mirrors [flags]         mirror the repos\n" +
+			"  git-mirrors status [flags]  print the sync history of repos in the state store of --cache-path or --config\n\n" +
+			"Flags:")
 		flag.PrintDefaults()
 	}
 }
 
 func parseParams() error {
+	if _, err := (&mirrors.Result{}).IsFailed(failOn); err != nil {
+		return err
+	}
+	if reportFormat != constants.ReportFormatJSON && reportFormat != constants.ReportFormatJUnit {
+		return fmt.Errorf("un-support report format %s", reportFormat)
+	}
+
+	// jobs from config file
+	if configPath != "" {
+		conf, err := config.Load(configPath)
+		if err != nil {
+			return err
+		}
+		parallel, jobs = conf.Parallel, conf.Jobs
+		return nil
+	}
+
 	// parse black and white list
 	if blackListStr == "" {
 		blackList = []string{}
@@ -105,39 +202,8 @@ func parseParams() error {
 		whiteList = strings.Split(whiteListStr, ",")
 	}
 
-	// check source and destination git service
-	checkGitSource := func(str string) ([]string, bool) {
-		if gitInfo := strings.Split(str, "/"); len(gitInfo) == 2 {
-			for _, g := range constants.SupportGit {
-				if g == gitInfo[0] {
-					return gitInfo, true
-				}
-			}
-		}
-
-		return nil, false
-	}
-
-	if gitInfo, ok := checkGitSource(src); ok {
-		return fmt.Errorf("un-support git source %s", src)
-	} else {
-		srcGit, srcOrg = gitInfo[0], gitInfo[1]
-	}
-	if gitInfo, ok := checkGitSource(dst); !ok {
-		return fmt.Errorf("un-support git destination %s", src)
-	} else {
-		dstGit, dstOrg = gitInfo[0], gitInfo[1]
-	}
-
-	// account type
-	if srcAccountType == "" {
-		srcAccountType = accountType
-	}
-	if dstAccountType == "" {
-		dstAccountType = accountType
-	}
-
 	// parse mappings
+	mappings = map[string]string{}
 	maps := strings.Split(mappingsStr, ",")
 	for _, m := range maps {
 		if m == "" {
@@ -150,11 +216,42 @@ func parseParams() error {
 		}
 	}
 
-	// parse timeout
-	var err error
-	timeout, err = time.ParseDuration(timeoutStr)
-	if err != nil {
-		return fmt.Errorf("parse timeout %s err: %s", timeoutStr, err.Error())
+	// parse plain git repos
+	if strings.HasPrefix(srcReposStr, "@") {
+		content, err := os.ReadFile(srcReposStr[1:])
+		if err != nil {
+			return fmt.Errorf("read src repos file %s err: %s", srcReposStr[1:], err.Error())
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				srcRepos = append(srcRepos, line)
+			}
+		}
+	} else if srcReposStr != "" {
+		srcRepos = strings.Split(srcReposStr, ",")
+	}
+
+	// parse destination name rules, regex may contain ','
+	var nameRules []string
+	if nameRulesStr != "" {
+		nameRules = strings.Split(nameRulesStr, ";")
+	}
+
+	// parse repo filter
+	var requireTopics, languages []string
+	if requireTopic != "" {
+		requireTopics = strings.Split(requireTopic, ",")
+	}
+	if language != "" {
+		languages = strings.Split(language, ",")
+	}
+
+	// parse ref filter and renames
+	splitPatterns := func(str string) []string {
+		if str == "" {
+			return nil
+		}
+		return strings.Split(str, ",")
 	}
 
 	// token check
@@ -162,6 +259,145 @@ func parseParams() error {
 		logger.Warn("un-configure srcToken, Only mirror Public Repos")
 	}
 
+	job := &config.Job{
+		Src:             src,
+		SrcToken:        srcToken,
+		SrcAPIURL:       srcAPIURL,
+		SrcUploadURL:    srcUploadURL,
+		SrcAccountType:  srcAccountType,
+		SrcURLTemplate:  srcURLTemplate,
+		SrcRepos:        srcRepos,
+		Dst:             dst,
+		DstKey:          dstKey,
+		DstToken:        dstToken,
+		DstAPIURL:       dstAPIURL,
+		DstUploadURL:    dstUploadURL,
+		DstAccountType:  dstAccountType,
+		DstURLTemplate:  dstURLTemplate,
+		AccountType:     accountType,
+		CloneStyle:      cloneStyle,
+		CachePath:       cachePath,
+		BlackList:       blackList,
+		WhiteList:       whiteList,
+		Mappings:        mappings,
+		ForceUpdate:     &forceUpdate,
+		Debug:           &debug,
+		Timeout:         timeoutStr,
+		Concurrency:     concurrency,
+		APIRate:         apiRate,
+		DryRun:          &dryRun,
+		SkipForks:       &skipForks,
+		SkipArchived:    &skipArchived,
+		Visibility:      visibility,
+		RequireTopics:   requireTopics,
+		Languages:       languages,
+		PushedSince:     pushedSince,
+		NameRules:       nameRules,
+		IncludeBranches: splitPatterns(includeBranch),
+		ExcludeBranches: splitPatterns(excludeBranch),
+		IncludeTags:     splitPatterns(includeTag),
+		ExcludeTags:     splitPatterns(excludeTag),
+		PruneExcluded:   &pruneExcluded,
+		RefRenames:      splitPatterns(refRenamesStr),
+		ExtraRefs:       splitPatterns(extraRefsStr),
+		GitBackend:      gitBackend,
+		StateStore:      stateStore,
+		RetryAttempts:   retryAttempts,
+		RetryBackoff:    retryBackoff,
+		RetryJitter:     &retryJitter,
+		OrphanPolicy:    orphanPolicy,
+		ConfirmDelete:   &confirmDelete,
+	}
+	if err := job.Validate(); err != nil {
+		return err
+	}
+	parallel, jobs = 1, []*config.Job{job}
+
+	return nil
+}
+
+// runJobs run the mirror of jobs, at most parallel jobs at the same time, return the merged result.
+// the job which can not start is a failed repo in the result
+func runJobs() *mirrors.Result {
+	start := time.Now()
+	results := make([]*mirrors.Result, len(jobs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i, job := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, job *config.Job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if len(jobs) > 1 {
+				logger.Infof("[%d/%d] begin job %s", i+1, len(jobs), job.Name)
+			}
+			mirror := job.NewMirror()
+			result, err := mirror.Do()
+			if err != nil {
+				logger.Errorf("[%d/%d] job %s occur err: %s", i+1, len(jobs), job.Name, err.Error())
+				result = mirror.FailedResult(err)
+			}
+			results[i] = result
+		}(i, job)
+	}
+	wg.Wait()
+
+	total := &mirrors.Result{}
+	for _, result := range results {
+		total.Merge(result)
+	}
+	total.Duration = time.Since(start)
+	if len(jobs) > 1 {
+		logger.Printf("all jobs done: %s", total)
+	}
+
+	return total
+}
+
+// runStatus print the sync history in the state stores of jobs, the jobs with the same store are printed once
+func runStatus() error {
+	if err := flag.CommandLine.Parse(flag.Args()[1:]); err != nil {
+		return err
+	}
+
+	type store struct{ kind, cachePath string }
+	var stores []store
+	if configPath != "" {
+		conf, err := config.Load(configPath)
+		if err != nil {
+			return err
+		}
+		added := make(map[store]bool)
+		for _, job := range conf.Jobs {
+			s := store{job.StateStore, job.CachePath}
+			if !added[s] {
+				added[s] = true
+				stores = append(stores, s)
+			}
+		}
+	} else {
+		stores = []store{{stateStore, cachePath}}
+	}
+
+	for _, s := range stores {
+		if s.kind == constants.StateStoreNone {
+			continue
+		}
+		states, err := mirrors.ReadStates(s.kind, s.cachePath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s state of %s: %d repos\n", s.kind, s.cachePath, len(states))
+		if len(states) == 0 {
+			continue
+		}
+		if err := mirrors.PrintStates(os.Stdout, states); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -181,16 +417,42 @@ func main() {
 		logger.SetLogLevel(logger.DEBUG)
 	}
 
+	if flag.Arg(0) == "status" {
+		if err := runStatus(); err != nil {
+			logger.Fatal(err.Error())
+			os.Exit(exitError)
+		}
+		return
+	}
+
 	if err := parseParams(); err != nil {
 		logger.Fatal(err.Error())
 		os.Exit(1)
 	}
 
-	mirror := mirrors.New(srcGit, srcOrg, srcToken, dstGit, dstOrg, dstKey, dstToken, srcAccountType, dstAccountType,
-		cloneStyle, cachePath, blackList, whiteList, forceUpdate, debug, timeout, mappings)
-	err := mirror.Do()
-	if err != nil {
-		logger.Fatalf("%s", err.Error())
-		os.Exit(1)
+	result := runJobs()
+	if reportFile != "" {
+		if err := mirrors.WriteReport(result, reportFormat, reportFile); err != nil {
+			logger.Errorf("%s", err.Error())
+		} else {
+			logger.Infof("write %s report to %s", reportFormat, reportFile)
+		}
+	}
+
+	if failed, _ := result.IsFailed(failOn); failed {
+		for _, repo := range result.Repos {
+			if repo.Status == mirrors.RepoFailed {
+				logger.Errorf("mirror %s failed: %s", repo.SrcFullName, repo.Err.Error())
+			}
+		}
+		for _, orphan := range result.Orphans {
+			if orphan.Err != nil {
+				logger.Errorf("%s orphan %s failed: %s", orphan.Action, orphan.DstFullName, orphan.Err.Error())
+			}
+		}
+		if result.IsTotalFailure() {
+			os.Exit(exitTotalFailure)
+		}
+		os.Exit(exitPartialFailure)
 	}
 }
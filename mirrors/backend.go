@@ -0,0 +1,137 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+// GitBackend is the implementation of the git operations of GitClient, the local repository is bare,
+// and the refs mirrored are selected by the RefFilter, RefRenames and ExtraRefSpecs of GitClient
+type GitBackend interface {
+	// Clone clone url to path as bare repository, the remote is `origin`
+	Clone(url, path string) error
+	// Fetch mirror the selected namespaces of remote to the repository in path, with force and prune
+	Fetch(remoteName, path string) error
+	// CreateRemote create the remote, or update its url
+	CreateRemote(urls []string, remoteName, path string) error
+	// Mirror push the selected namespaces to remote with prune, return the refs updated in remote
+	Mirror(remoteName, path string, force bool) (*PushResult, error)
+	// ListRemote list the hash references of url, like `git ls-remote`
+	ListRemote(url string) (map[string]string, error)
+}
+
+// goGitBackend run git operations by go-git
+type goGitBackend struct {
+	*GitClient
+}
+
+// SetBackend select the backend of git operations, go-git(default) or the git cli
+func (c *GitClient) SetBackend(name string) error {
+	switch name {
+	case "", constants.GitBackendGoGit:
+		c.backend = &goGitBackend{c}
+	case constants.GitBackendCLI:
+		if _, err := exec.LookPath("git"); err != nil {
+			return fmt.Errorf("git backend %s requires git binary: %s", name, err.Error())
+		}
+		c.backend = &cliBackend{c}
+	default:
+		return fmt.Errorf("un-support git backend %s", name)
+	}
+
+	return nil
+}
+
+// Clone clone git to local directory as bare repository, the transient failures are retried by Retry
+func (c *GitClient) Clone(url, path string) error {
+	attempt := 0
+	return c.Retry.Do(fmt.Sprintf("[git clone %s]", url), func() error {
+		// remove the partial clone of the failed attempt
+		if attempt += 1; attempt > 1 {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+		}
+		return c.backend.Clone(url, path)
+	})
+}
+
+// Fetch git repo changes to local directory, the transient failures are retried by Retry
+func (c *GitClient) Fetch(remoteName, path string) error {
+	if remoteName == "" {
+		remoteName = "origin"
+	}
+	return c.Retry.Do(fmt.Sprintf("[git fetch %s] in path %s", remoteName, path), func() error {
+		return c.backend.Fetch(remoteName, path)
+	})
+}
+
+// CreateRemote create remote
+// url eg. https://github.com/git-fixtures/basic.git
+func (c *GitClient) CreateRemote(urls []string, remoteName, path string) error {
+	return c.backend.CreateRemote(urls, remoteName, path)
+}
+
+// Mirror push the mirrored namespaces to remoteName remote, return the refs updated in remote.
+// the transient failures are retried by Retry, the refs to push are computed again in every attempt
+func (c *GitClient) Mirror(remoteName, path string, force bool) (*PushResult, error) {
+	if remoteName == "" {
+		remoteName = "origin"
+	}
+	var result *PushResult
+	err := c.Retry.Do(fmt.Sprintf("[git push %s] in path %s", remoteName, path), func() (err error) {
+		result, err = c.backend.Mirror(remoteName, path, force)
+		return err
+	})
+	return result, err
+}
+
+// ListRemoteRefs list all the hash references of remote url without local repository
+func (c *GitClient) ListRemoteRefs(url string) (map[string]string, error) {
+	return c.backend.ListRemote(url)
+}
+
+// mirrorTargets return the refs mirrored from srcRefs in the mirrored namespaces, keyed by the name in remote,
+// srcRefs are the hash references of remote repository
+func (c *GitClient) mirrorTargets(srcRefs map[string]string) (map[string]string, error) {
+	mirrorRefSpecs := c.mirrorRefSpecs()
+	selected := make(map[string]string, len(srcRefs))
+	for name, hash := range srcRefs {
+		if config.MatchAny(mirrorRefSpecs, plumbing.ReferenceName(name)) {
+			selected[name] = hash
+		}
+	}
+
+	return mirroredRefs(selected, c.RefFilter, c.RefRenames)
+}
+
+// upToDate check the refs mirrored from srcRefs are all same in dstRefs, so there is nothing to push or prune.
+// srcRefs and dstRefs are the hash references of remote repositories
+func (c *GitClient) upToDate(srcRefs, dstRefs map[string]string) (bool, error) {
+	targets, err := c.mirrorTargets(srcRefs)
+	if err != nil {
+		return false, err
+	}
+
+	return len(diffRefs(targets, dstRefs)) == 0 && len(c.refsToPrune(targets, dstRefs)) == 0, nil
+}
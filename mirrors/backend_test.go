@@ -0,0 +1,119 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+func TestGitClient_SetBackend(t *testing.T) {
+	c, _ := NewGitNoneAuthClient(defaultTimeOut, false)
+	if err := c.SetBackend("jgit"); err == nil {
+		t.Error("expect un-support backend err")
+	}
+	if err := c.SetBackend(constants.GitBackendCLI); err != nil {
+		t.Skip(err.Error())
+	}
+	if _, ok := c.backend.(*cliBackend); !ok {
+		t.Errorf("expect cli backend, got %T", c.backend)
+	}
+}
+
+func TestCLIBackend_Env(t *testing.T) {
+	c, _ := NewGitAccessTokenClient("token", defaultTimeOut, false)
+	env := strings.Join((&cliBackend{c}).env(), "\n")
+	if !strings.Contains(env, "GIT_CONFIG_KEY_0=http.extraHeader") {
+		t.Errorf("expect credentials in http.extraHeader, got %s", env)
+	}
+	if strings.Contains(env, "http.sslVerify") {
+		t.Errorf("expect TLS is verified, got %s", env)
+	}
+}
+
+func TestMirror_Do_CLIBackend(t *testing.T) {
+	tmp := t.TempDir()
+	m := newTestMirror(t, tmp, "repo")
+	m.GitBackend = constants.GitBackendCLI
+	m.RefRenames = []string{"refs/tags/*:refs/tags/upstream/*"}
+	m.ExtraRefs = []string{"refs/notes/*"}
+
+	srcRepo, _ := git.PlainOpen(filepath.Join(tmp, "src", "repo"))
+	head, _ := srcRepo.Head()
+	for _, name := range []plumbing.ReferenceName{"refs/tags/v1.2", "refs/notes/commits"} {
+		if err := srcRepo.Storer.SetReference(plumbing.NewHashReference(name, head.Hash())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success != 1 {
+		t.Fatalf("unexpected result %s: %v", result, result.Repos[0].Err)
+	}
+	pushed := result.Repos[0].PushedRefs
+	expected := []string{"refs/heads/master", "refs/notes/commits", "refs/tags/upstream/v1.2"}
+	if len(pushed) != len(expected) {
+		t.Fatalf("expect pushed %v, got %v", expected, pushed)
+	}
+	for i := range expected {
+		if pushed[i] != expected[i] {
+			t.Errorf("expect pushed %v, got %v", expected, pushed)
+		}
+	}
+
+	// the cache is bare, and the plan is up-to-date
+	cacheRepo, err := git.PlainOpen(filepath.Join(tmp, "cache", "src", "repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _ := cacheRepo.Config(); !cfg.Core.IsBare {
+		t.Error("expect cache is bare")
+	}
+	m.DryRun = true
+	result, err = m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan := result.Repos[0]; len(plan.PushedRefs) != 0 || len(plan.DeletedRefs) != 0 {
+		t.Errorf("expect up-to-date plan, got push %v delete %v", plan.PushedRefs, plan.DeletedRefs)
+	}
+}
+
+func TestMirror_Do_CLIBackend_Empty(t *testing.T) {
+	tmp := t.TempDir()
+	if _, err := git.PlainInit(filepath.Join(tmp, "src", "empty"), true); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestMirror(t, tmp)
+	m.SrcRepos = []string{"empty"}
+	m.GitBackend = constants.GitBackendCLI
+
+	result, err := m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Empty != 1 {
+		t.Errorf("expect empty repo, got %s", result)
+	}
+}
@@ -14,11 +14,13 @@
 
 package mirrors
 
+import "time"
+
 type IMirror interface {
-	Do() error
+	Do() (*Result, error)
 	prepare() error
-	mirrorRepoInfo(srcRepo *Repository, dstRepoName string) (*Repository, error)
-	mirrorGit(srcRepo, dstRepo *Repository) error
+	mirrorRepoInfo(srcRepo *Repository, dstRepoName string) (*Repository, []string, error)
+	mirrorGit(srcRepo, dstRepo *Repository) (*PushResult, int64, error)
 }
 
 type IGitAPI interface {
@@ -30,6 +32,8 @@ type IGitAPI interface {
 	CreateRepository(baseRepo *Repository, orgName string) (*Repository, error)
 	UpdateRepository(orgName, repoName string, baseRepo *Repository) (*Repository, error)
 	RepositoriesByOrg(orgName string) ([]*Repository, error)
+	DeleteRepository(orgName, repoName string) error
+	ArchiveRepository(orgName, repoName string) error
 }
 
 type User struct {
@@ -57,6 +61,8 @@ type Repository struct {
 	Fork         *bool         `json:"fork,omitempty"`
 	Organization *Organization `json:"organization,omitempty"`
 	Topics       []string      `json:"topics,omitempty"`
+	Language     *string       `json:"language,omitempty"`
+	PushedAt     *time.Time    `json:"pushed_at,omitempty"` // the last push time, or the last activity time if the git service has no push time
 
 	// Additional mutable fields when creating and editing a repository
 	Private  *bool `json:"private,omitempty"`
@@ -0,0 +1,349 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Bitbucket Cloud REST API 2.0, workspaces are mapped to Organization
+
+package mirrors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xiexianbin/golib/logger"
+)
+
+const (
+	maxBitbucketPerPage     = 100
+	defaultBitbucketBaseURL = "https://api.bitbucket.org/2.0"
+)
+
+type bitbucketLink struct {
+	Name string `json:"name"`
+	Href string `json:"href"`
+}
+
+type bitbucketWorkspace struct {
+	UUID string `json:"uuid"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type bitbucketRepository struct {
+	UUID        string               `json:"uuid"`
+	Slug        string               `json:"slug"`
+	Name        string               `json:"name"`
+	FullName    string               `json:"full_name"`
+	Description string               `json:"description"`
+	Website     string               `json:"website"`
+	IsPrivate   bool                 `json:"is_private"`
+	Language    string               `json:"language"`
+	UpdatedOn   *time.Time           `json:"updated_on"`
+	Parent      *bitbucketRepository `json:"parent"`
+	Workspace   *bitbucketWorkspace  `json:"workspace"`
+	Links       struct {
+		HTML  bitbucketLink   `json:"html"`
+		Clone []bitbucketLink `json:"clone"`
+	} `json:"links"`
+}
+
+// bitbucketPage is the paginated response of bitbucket cloud, next is the url of next page
+type bitbucketPage struct {
+	Next   string          `json:"next"`
+	Values json.RawMessage `json:"values"`
+}
+
+type BitbucketAPI struct {
+	Client      *restClient
+	Context     context.Context
+	accessToken string
+	IsAuthed    bool
+}
+
+// NewBitbucketAPI return new Bitbucket Cloud API, baseURL default is https://api.bitbucket.org/2.0.
+// accessToken is a repository/workspace access token, or `username:app_password`
+func NewBitbucketAPI(baseURL, accessToken string) (*BitbucketAPI, error) {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = defaultBitbucketBaseURL
+	}
+
+	var client *restClient
+	if username, password, ok := strings.Cut(accessToken, ":"); ok {
+		client = newBasicAuthRESTClient(ctx, baseURL, username, password)
+	} else {
+		client = newRESTClient(ctx, baseURL, accessToken)
+	}
+
+	return &BitbucketAPI{Client: client, Context: ctx, accessToken: accessToken, IsAuthed: accessToken != ""}, nil
+}
+
+// IsAPIAuthed return is the API auth, true or false
+func (b *BitbucketAPI) IsAPIAuthed() bool {
+	return b.IsAuthed
+}
+
+// list follow the next link of pages, and call fn with the values of every page
+func (b *BitbucketAPI) list(path string, fn func(values json.RawMessage) error) error {
+	query := url.Values{}
+	query.Set("pagelen", strconv.Itoa(maxBitbucketPerPage))
+	for path != "" {
+		var page bitbucketPage
+		resp, err := b.Client.do(http.MethodGet, path, query, nil, &page)
+		if err != nil {
+			if isStatus(resp, http.StatusNotFound) {
+				return ErrNotFound("Workspace", path)
+			}
+			return err
+		}
+		if err := fn(page.Values); err != nil {
+			return err
+		}
+
+		// next already contains the query
+		path, query = page.Next, nil
+	}
+
+	return nil
+}
+
+// Organizations list workspaces which the authenticated user has access to
+func (b *BitbucketAPI) Organizations(user string) ([]*Organization, error) {
+	var baseOrgs []*Organization
+	err := b.list("/user/permissions/workspaces", func(values json.RawMessage) error {
+		var permissions []struct {
+			Workspace *bitbucketWorkspace `json:"workspace"`
+		}
+		if err := json.Unmarshal(values, &permissions); err != nil {
+			return err
+		}
+		for _, p := range permissions {
+			baseOrgs = append(baseOrgs, formatBitbucketWorkspace(p.Workspace))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return baseOrgs, nil
+}
+
+// GetOrganization get a workspace by slug
+func (b *BitbucketAPI) GetOrganization(orgName string) (*Organization, error) {
+	if orgName == "" {
+		return nil, fmt.Errorf("workspace name must not be empty")
+	}
+
+	var workspace bitbucketWorkspace
+	resp, err := b.Client.do(http.MethodGet, "/workspaces/"+url.PathEscape(orgName), nil, nil, &workspace)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return nil, ErrNotFound("Organization", orgName)
+		}
+		return nil, err
+	}
+	logger.Debugf("get bitbucket %s workspace: %v", orgName, workspace)
+
+	return formatBitbucketWorkspace(&workspace), nil
+}
+
+// Repositories list all repositories in the personal workspace of user, if user is empty use the authenticated user
+func (b *BitbucketAPI) Repositories(user string) ([]*Repository, error) {
+	if user == "" {
+		var u struct {
+			UserName string `json:"username"`
+		}
+		if _, err := b.Client.do(http.MethodGet, "/user", nil, nil, &u); err != nil {
+			return nil, fmt.Errorf("get bitbucket authenticated user err: %s", err.Error())
+		}
+		user = u.UserName
+	}
+
+	return b.RepositoriesByOrg(user)
+}
+
+// GetRepository fetches a repository
+func (b *BitbucketAPI) GetRepository(orgName, repoName string) (*Repository, error) {
+	var repo bitbucketRepository
+	resp, err := b.Client.do(http.MethodGet, bitbucketRepoPath(orgName, repoName), nil, nil, &repo)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return nil, ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return nil, err
+	}
+
+	return formatBitbucketRepo(&repo), nil
+}
+
+// CreateRepository create a new repository in workspace orgName, if repo is already exist, just return it
+func (b *BitbucketAPI) CreateRepository(baseRepo *Repository, orgName string) (*Repository, error) {
+	if baseRepo.Name == nil || *baseRepo.Name == "" {
+		return nil, fmt.Errorf("new repo name must not be empty")
+	}
+
+	body := map[string]interface{}{
+		"scm": "git",
+	}
+	if baseRepo.Description != nil {
+		body["description"] = *baseRepo.Description
+	}
+	if baseRepo.Homepage != nil {
+		body["website"] = *baseRepo.Homepage
+	}
+	if baseRepo.Private != nil {
+		body["is_private"] = *baseRepo.Private
+	}
+
+	var repo bitbucketRepository
+	resp, err := b.Client.do(http.MethodPost, bitbucketRepoPath(orgName, *baseRepo.Name), nil, body, &repo)
+	if err != nil {
+		if isStatus(resp, http.StatusBadRequest) && strings.Contains(err.Error(), "already exists") {
+			if baseRepo, err := b.GetRepository(orgName, *baseRepo.Name); err == nil {
+				return baseRepo, nil
+			}
+		}
+		return nil, err
+	}
+
+	return formatBitbucketRepo(&repo), nil
+}
+
+// UpdateRepository updates a repository, bitbucket do not support topics
+func (b *BitbucketAPI) UpdateRepository(orgName, repoName string, baseRepo *Repository) (*Repository, error) {
+	body := map[string]interface{}{}
+	if baseRepo.Description != nil {
+		body["description"] = *baseRepo.Description
+	}
+	if baseRepo.Homepage != nil {
+		body["website"] = *baseRepo.Homepage
+	}
+	if baseRepo.Private != nil {
+		body["is_private"] = *baseRepo.Private
+	}
+
+	var repo bitbucketRepository
+	resp, err := b.Client.do(http.MethodPut, bitbucketRepoPath(orgName, repoName), nil, body, &repo)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return nil, ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return nil, err
+	}
+
+	return formatBitbucketRepo(&repo), nil
+}
+
+// DeleteRepository delete a repository
+func (b *BitbucketAPI) DeleteRepository(orgName, repoName string) error {
+	resp, err := b.Client.do(http.MethodDelete, bitbucketRepoPath(orgName, repoName), nil, nil, nil)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return err
+	}
+	return nil
+}
+
+// ArchiveRepository bitbucket cloud has no archived repository, use make-private instead
+func (b *BitbucketAPI) ArchiveRepository(orgName, repoName string) error {
+	return ErrNotSupported("bitbucket", "archive repository")
+}
+
+// RepositoriesByOrg list repositories for special workspace
+func (b *BitbucketAPI) RepositoriesByOrg(orgName string) ([]*Repository, error) {
+	var baseRepos []*Repository
+	err := b.list("/repositories/"+url.PathEscape(orgName), func(values json.RawMessage) error {
+		var repos []*bitbucketRepository
+		if err := json.Unmarshal(values, &repos); err != nil {
+			return err
+		}
+		for _, repo := range repos {
+			baseRepos = append(baseRepos, formatBitbucketRepo(repo))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list bitbucket workspace %s repos err: %s", orgName, err.Error())
+	}
+
+	return baseRepos, nil
+}
+
+func bitbucketRepoPath(orgName, repoName string) string {
+	return fmt.Sprintf("/repositories/%s/%s", url.PathEscape(orgName), url.PathEscape(repoName))
+}
+
+// bitbucketCloneURL find the clone url by name, and remove the user info, eg: https://user@bitbucket.org/ws/repo.git
+func bitbucketCloneURL(links []bitbucketLink, name string) string {
+	for _, link := range links {
+		if link.Name != name {
+			continue
+		}
+		if u, err := url.Parse(link.Href); err == nil && u.Scheme != "ssh" {
+			u.User = nil
+			return u.String()
+		}
+		return link.Href
+	}
+
+	return ""
+}
+
+func formatBitbucketWorkspace(workspace *bitbucketWorkspace) *Organization {
+	orgType := "workspace"
+	baseOrg := &Organization{
+		Name:        &workspace.Slug,
+		Description: &workspace.Name,
+		Type:        &orgType,
+	}
+
+	return baseOrg
+}
+
+func formatBitbucketRepo(repo *bitbucketRepository) *Repository {
+	fork := repo.Parent != nil
+	cloneURL := bitbucketCloneURL(repo.Links.Clone, "https")
+	sshURL := bitbucketCloneURL(repo.Links.Clone, "ssh")
+	baseRepo := &Repository{
+		Name:        &repo.Slug,
+		FullName:    &repo.FullName,
+		Description: &repo.Description,
+		HTMLURL:     &repo.Links.HTML.Href,
+		CloneURL:    &cloneURL,
+		GitURL:      &sshURL,
+		SSHURL:      &sshURL,
+		Homepage:    &repo.Website,
+		Fork:        &fork,
+		Language:    &repo.Language,
+		Private:     &repo.IsPrivate,
+		PushedAt:    repo.UpdatedOn,
+	}
+
+	if repo.Workspace != nil {
+		baseRepo.Owner = &User{
+			Name: &repo.Workspace.Slug,
+		}
+		baseRepo.Organization = formatBitbucketWorkspace(repo.Workspace)
+	}
+
+	return baseRepo
+}
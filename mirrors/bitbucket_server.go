@@ -0,0 +1,353 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Bitbucket Data Center (Server) REST API 1.0, projects are mapped to Organization,
+// and the personal project of user is `~username`
+
+package mirrors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/xiexianbin/golib/logger"
+)
+
+const (
+	maxBitbucketServerPerPage = 100
+)
+
+type bitbucketServerProject struct {
+	ID          int    `json:"id"`
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        string `json:"type"` // NORMAL or PERSONAL
+}
+
+type bitbucketServerRepository struct {
+	ID          int                        `json:"id"`
+	Slug        string                     `json:"slug"`
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Public      bool                       `json:"public"`
+	Archived    bool                       `json:"archived"`
+	Origin      *bitbucketServerRepository `json:"origin"`
+	Project     *bitbucketServerProject    `json:"project"`
+	Links       struct {
+		Self  []bitbucketLink `json:"self"`
+		Clone []bitbucketLink `json:"clone"`
+	} `json:"links"`
+}
+
+// bitbucketServerPage is the paginated response of bitbucket data center, use nextPageStart as start of next page
+type bitbucketServerPage struct {
+	IsLastPage    bool            `json:"isLastPage"`
+	NextPageStart int             `json:"nextPageStart"`
+	Values        json.RawMessage `json:"values"`
+}
+
+type BitbucketServerAPI struct {
+	Client      *restClient
+	Context     context.Context
+	accessToken string
+	IsAuthed    bool
+}
+
+// NewBitbucketServerAPI return new Bitbucket Data Center API, baseURL is like https://bitbucket.example.com/rest/api/1.0.
+// accessToken is a http access token, or `username:password`
+func NewBitbucketServerAPI(baseURL, accessToken string) (*BitbucketServerAPI, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("bitbucket data center api url must not be empty")
+	}
+	ctx := context.Background()
+
+	var client *restClient
+	if username, password, ok := strings.Cut(accessToken, ":"); ok {
+		client = newBasicAuthRESTClient(ctx, baseURL, username, password)
+	} else {
+		client = newRESTClient(ctx, baseURL, accessToken)
+	}
+
+	return &BitbucketServerAPI{Client: client, Context: ctx, accessToken: accessToken, IsAuthed: accessToken != ""}, nil
+}
+
+// IsAPIAuthed return is the API auth, true or false
+func (b *BitbucketServerAPI) IsAPIAuthed() bool {
+	return b.IsAuthed
+}
+
+// list request the pages by start and limit, and call fn with the values of every page
+func (b *BitbucketServerAPI) list(path string, fn func(values json.RawMessage) error) error {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(maxBitbucketServerPerPage))
+	start := 0
+	for {
+		query.Set("start", strconv.Itoa(start))
+		var page bitbucketServerPage
+		resp, err := b.Client.do(http.MethodGet, path, query, nil, &page)
+		if err != nil {
+			if isStatus(resp, http.StatusNotFound) {
+				return ErrNotFound("Project", path)
+			}
+			return err
+		}
+		if err := fn(page.Values); err != nil {
+			return err
+		}
+
+		if page.IsLastPage {
+			break
+		}
+
+		start = page.NextPageStart
+	}
+
+	return nil
+}
+
+// Organizations list projects which the authenticated user has access to
+func (b *BitbucketServerAPI) Organizations(user string) ([]*Organization, error) {
+	var baseOrgs []*Organization
+	err := b.list("/projects", func(values json.RawMessage) error {
+		var projects []*bitbucketServerProject
+		if err := json.Unmarshal(values, &projects); err != nil {
+			return err
+		}
+		for _, project := range projects {
+			baseOrgs = append(baseOrgs, formatBitbucketServerProject(project))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return baseOrgs, nil
+}
+
+// GetOrganization get a project by key
+func (b *BitbucketServerAPI) GetOrganization(orgName string) (*Organization, error) {
+	if orgName == "" {
+		return nil, fmt.Errorf("project key must not be empty")
+	}
+
+	var project bitbucketServerProject
+	resp, err := b.Client.do(http.MethodGet, "/projects/"+url.PathEscape(orgName), nil, nil, &project)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return nil, ErrNotFound("Organization", orgName)
+		}
+		return nil, err
+	}
+	logger.Debugf("get bitbucket data center %s project: %v", orgName, project)
+
+	return formatBitbucketServerProject(&project), nil
+}
+
+// Repositories list all repositories in the personal project of user, if user is empty use the authenticated user
+func (b *BitbucketServerAPI) Repositories(user string) ([]*Repository, error) {
+	if user == "" {
+		// the authenticated user name is returned in X-AUSERNAME header
+		resp, err := b.Client.do(http.MethodGet, "/application-properties", nil, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("get bitbucket data center authenticated user err: %s", err.Error())
+		}
+		if user = resp.Header.Get("X-AUSERNAME"); user == "" {
+			return nil, fmt.Errorf("get bitbucket data center authenticated user err: anonymous")
+		}
+	}
+
+	return b.RepositoriesByOrg(bitbucketServerProjectKey(user))
+}
+
+// GetRepository fetches a repository
+func (b *BitbucketServerAPI) GetRepository(orgName, repoName string) (*Repository, error) {
+	var repo bitbucketServerRepository
+	resp, err := b.Client.do(http.MethodGet, bitbucketServerRepoPath(orgName, repoName), nil, nil, &repo)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return nil, ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return nil, err
+	}
+
+	return formatBitbucketServerRepo(&repo), nil
+}
+
+// CreateRepository create a new repository, if orgName is not a project, create it in the personal project of user orgName.
+// if repo is already exist, just return it
+func (b *BitbucketServerAPI) CreateRepository(baseRepo *Repository, orgName string) (*Repository, error) {
+	if baseRepo.Name == nil || *baseRepo.Name == "" {
+		return nil, fmt.Errorf("new repo name must not be empty")
+	}
+
+	if _, err := b.GetOrganization(orgName); err != nil {
+		orgName = bitbucketServerProjectKey(orgName)
+	}
+
+	body := map[string]interface{}{
+		"name":  *baseRepo.Name,
+		"scmId": "git",
+	}
+	if baseRepo.Description != nil {
+		body["description"] = *baseRepo.Description
+	}
+	if baseRepo.Private != nil {
+		body["public"] = !*baseRepo.Private
+	}
+
+	var repo bitbucketServerRepository
+	path := fmt.Sprintf("/projects/%s/repos", url.PathEscape(orgName))
+	resp, err := b.Client.do(http.MethodPost, path, nil, body, &repo)
+	if err != nil {
+		if isStatus(resp, http.StatusConflict) {
+			if baseRepo, err := b.GetRepository(orgName, *baseRepo.Name); err == nil {
+				return baseRepo, nil
+			}
+		}
+		return nil, err
+	}
+
+	return formatBitbucketServerRepo(&repo), nil
+}
+
+// UpdateRepository updates a repository, bitbucket data center do not support homepage and topics
+func (b *BitbucketServerAPI) UpdateRepository(orgName, repoName string, baseRepo *Repository) (*Repository, error) {
+	body := map[string]interface{}{}
+	if baseRepo.Description != nil {
+		body["description"] = *baseRepo.Description
+	}
+	if baseRepo.Private != nil {
+		body["public"] = !*baseRepo.Private
+	}
+
+	var repo bitbucketServerRepository
+	resp, err := b.Client.do(http.MethodPut, bitbucketServerRepoPath(orgName, repoName), nil, body, &repo)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return nil, ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return nil, err
+	}
+
+	return formatBitbucketServerRepo(&repo), nil
+}
+
+// DeleteRepository delete a repository
+func (b *BitbucketServerAPI) DeleteRepository(orgName, repoName string) error {
+	resp, err := b.Client.do(http.MethodDelete, bitbucketServerRepoPath(orgName, repoName), nil, nil, nil)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return err
+	}
+	return nil
+}
+
+// ArchiveRepository archive a repository, it is supported since Bitbucket Data Center 8.0
+func (b *BitbucketServerAPI) ArchiveRepository(orgName, repoName string) error {
+	body := map[string]interface{}{"archived": true}
+	resp, err := b.Client.do(http.MethodPut, bitbucketServerRepoPath(orgName, repoName), nil, body, nil)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return err
+	}
+	return nil
+}
+
+// RepositoriesByOrg list repositories for special project
+func (b *BitbucketServerAPI) RepositoriesByOrg(orgName string) ([]*Repository, error) {
+	var baseRepos []*Repository
+	err := b.list(fmt.Sprintf("/projects/%s/repos", url.PathEscape(orgName)), func(values json.RawMessage) error {
+		var repos []*bitbucketServerRepository
+		if err := json.Unmarshal(values, &repos); err != nil {
+			return err
+		}
+		for _, repo := range repos {
+			baseRepos = append(baseRepos, formatBitbucketServerRepo(repo))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list bitbucket data center project %s repos err: %s", orgName, err.Error())
+	}
+
+	return baseRepos, nil
+}
+
+// bitbucketServerProjectKey return the personal project key of user
+func bitbucketServerProjectKey(user string) string {
+	if strings.HasPrefix(user, "~") {
+		return user
+	}
+	return "~" + user
+}
+
+func bitbucketServerRepoPath(orgName, repoName string) string {
+	return fmt.Sprintf("/projects/%s/repos/%s", url.PathEscape(orgName), url.PathEscape(repoName))
+}
+
+func formatBitbucketServerProject(project *bitbucketServerProject) *Organization {
+	baseOrg := &Organization{
+		Name:        &project.Key,
+		Description: &project.Description,
+		Type:        &project.Type,
+	}
+
+	return baseOrg
+}
+
+func formatBitbucketServerRepo(repo *bitbucketServerRepository) *Repository {
+	private := !repo.Public
+	fork := repo.Origin != nil
+	cloneURL := bitbucketCloneURL(repo.Links.Clone, "http")
+	sshURL := bitbucketCloneURL(repo.Links.Clone, "ssh")
+	var htmlURL string
+	if len(repo.Links.Self) > 0 {
+		htmlURL = repo.Links.Self[0].Href
+	}
+
+	baseRepo := &Repository{
+		Name:        &repo.Slug,
+		Description: &repo.Description,
+		HTMLURL:     &htmlURL,
+		CloneURL:    &cloneURL,
+		GitURL:      &sshURL,
+		SSHURL:      &sshURL,
+		Fork:        &fork,
+		Private:     &private,
+		Archived:    &repo.Archived,
+	}
+
+	if repo.Project != nil {
+		fullName := repo.Project.Key + "/" + repo.Slug
+		baseRepo.FullName = &fullName
+		baseRepo.Owner = &User{
+			Name: &repo.Project.Key,
+			Type: &repo.Project.Type,
+		}
+		baseRepo.Organization = formatBitbucketServerProject(repo.Project)
+	}
+
+	return baseRepo
+}
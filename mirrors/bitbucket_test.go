@@ -0,0 +1,162 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestBitbucket_RepositoriesByOrg(t *testing.T) {
+	var ts *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/2.0/repositories/ws", func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "app-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		var values []map[string]interface{}
+		for i := 0; i < 2; i++ {
+			slug := fmt.Sprintf("repo%d", (page-1)*2+i)
+			values = append(values, map[string]interface{}{
+				"slug":       slug,
+				"full_name":  "ws/" + slug,
+				"is_private": true,
+				"workspace":  map[string]interface{}{"slug": "ws"},
+				"links": map[string]interface{}{
+					"clone": []map[string]interface{}{
+						{"name": "https", "href": "https://user@bitbucket.org/ws/" + slug + ".git"},
+						{"name": "ssh", "href": "git@bitbucket.org:ws/" + slug + ".git"},
+					},
+				},
+			})
+		}
+		result := map[string]interface{}{"values": values}
+		if page < 2 {
+			result["next"] = fmt.Sprintf("%s/2.0/repositories/ws?page=%d", ts.URL, page+1)
+		}
+		_ = json.NewEncoder(w).Encode(result)
+	})
+	ts = httptest.NewServer(mux)
+	defer ts.Close()
+
+	c, _ := NewBitbucketAPI(ts.URL+"/2.0", "user:app-password")
+	repos, err := c.RepositoriesByOrg("ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 4 {
+		t.Fatalf("expect 4 repos, got %d", len(repos))
+	}
+	if *repos[3].Name != "repo3" || *repos[3].CloneURL != "https://bitbucket.org/ws/repo3.git" ||
+		*repos[3].SSHURL != "git@bitbucket.org:ws/repo3.git" || *repos[3].Organization.Name != "ws" {
+		j, _ := json.Marshal(repos[3])
+		t.Fatalf("unexpected repo: %s", j)
+	}
+}
+
+func TestBitbucketServer_RepositoriesByOrg(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/api/1.0/projects/PRJ/repos", func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		var values []map[string]interface{}
+		for i := start; i < start+2; i++ {
+			slug := fmt.Sprintf("repo%d", i)
+			values = append(values, map[string]interface{}{
+				"slug":    slug,
+				"public":  i%2 == 0,
+				"project": map[string]interface{}{"key": "PRJ", "type": "NORMAL"},
+				"links": map[string]interface{}{
+					"clone": []map[string]interface{}{
+						{"name": "http", "href": "https://bitbucket.example.com/scm/prj/" + slug + ".git"},
+						{"name": "ssh", "href": "ssh://git@bitbucket.example.com:7999/prj/" + slug + ".git"},
+					},
+				},
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"values":        values,
+			"isLastPage":    start >= 2,
+			"nextPageStart": start + 2,
+		})
+	})
+	mux.HandleFunc("/rest/api/1.0/projects/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	if _, err := NewBitbucketServerAPI("", ""); err == nil {
+		t.Fatal("expect err when api url is empty")
+	}
+
+	c, _ := NewBitbucketServerAPI(ts.URL+"/rest/api/1.0", "token")
+	repos, err := c.RepositoriesByOrg("PRJ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 4 {
+		t.Fatalf("expect 4 repos, got %d", len(repos))
+	}
+	if *repos[1].FullName != "PRJ/repo1" || !*repos[1].Private ||
+		*repos[1].SSHURL != "ssh://git@bitbucket.example.com:7999/prj/repo1.git" {
+		j, _ := json.Marshal(repos[1])
+		t.Fatalf("unexpected repo: %s", j)
+	}
+
+	if _, err := c.GetRepository("PRJ", "not-exist"); err == nil {
+		t.Fatal("expect not found err")
+	}
+}
+
+func TestBitbucketServer_CreateRepository(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/api/1.0/projects/~user/repos", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["public"] != false {
+			t.Errorf("unexpected create repo body: %v", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"slug":    body["name"],
+			"project": map[string]interface{}{"key": "~user", "type": "PERSONAL"},
+		})
+	})
+	mux.HandleFunc("/rest/api/1.0/projects/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	c, _ := NewBitbucketServerAPI(ts.URL+"/rest/api/1.0", "token")
+	repo, err := c.CreateRepository(&Repository{Name: github.String("new-repo"), Private: github.Bool(true)}, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *repo.FullName != "~user/new-repo" {
+		t.Fatalf("unexpected repo full name %s", *repo.FullName)
+	}
+}
@@ -18,8 +18,12 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/xiexianbin/golib/logger"
 
@@ -44,9 +48,32 @@ type Mirror struct {
 	Debug          bool
 	Timeout        time.Duration
 	Mappings       map[string]string
-
-	blackListMap map[string]string
-	whiteListMap map[string]string
+	SrcAPIURL      string   // API base url of source, only for self-hosted git service, like gitlab, gitea
+	SrcUploadURL   string   // API upload url of source, only for github enterprise server
+	DstAPIURL      string   // API base url of destination
+	DstUploadURL   string   // API upload url of destination
+	SrcURLTemplate string   // repo url template of plain git source, like `ssh://git@host/{org}/{name}.git`
+	SrcRepos       []string // static repo list of plain git source
+	DstURLTemplate string   // repo url template of plain git destination
+	Concurrency    int      // the number of repos mirrored in parallel
+	APIRate        float64  // the max API requests per second of src and dst, shared by all workers, 0 is no limit
+	DryRun         bool     // only print the plan, do not create/update repos or push
+	Filter         *RepoFilter
+	NameRules      []string     // ordered rules to transform the destination name, after Mappings
+	RefFilter      *RefFilter   // the branches and tags to push and prune, nil is all
+	RefRenames     []string     // ordered rules to rename refs in destination, like `refs/heads/main:refs/heads/master`
+	ExtraRefs      []string     // extra ref namespaces to mirror besides branches and tags, like `refs/notes/*`
+	GitBackend     string       // the backend of git operations, go-git or cli
+	StateStore     string       // the store of sync state in CachePath, json, kv or none
+	Retry          *RetryPolicy // retry the transient failures of git and API operations, nil is no retry
+	OrphanPolicy   string       // the action on dst repos whose source is gone, keep, archive, make-private or delete
+	ConfirmDelete  bool         // confirm the orphan policy delete, which can not be undone
+
+	blackList  NameMatcher
+	whiteList  NameMatcher
+	nameRules  NameRules
+	refRenames RefRenames
+	extraRefs  []config.RefSpec
 
 	srcRepos    []*Repository
 	srcReposMap map[string]*Repository
@@ -57,6 +84,8 @@ type Mirror struct {
 	dstGitClient *GitClient
 	srcAPI       interface{}
 	dstAPI       interface{}
+	dstPlainGit  *PlainGit
+	state        StateStore
 }
 
 func New(srcGit, srcOrg, srcToken, dstGit, dstOrg, dstKey, dstToken, srcAccountType, dstAccountType, cloneStyle,
@@ -75,9 +104,7 @@ func New(srcGit, srcOrg, srcToken, dstGit, dstOrg, dstKey, dstToken, srcAccountT
 		CloneStyle:     cloneStyle,
 		CachePath:      cachePath,
 		BlackList:      RemoveDuplicates(blackList),
-		blackListMap:   StringListToMap(blackList),
 		WhiteList:      RemoveDuplicates(whiteList),
-		whiteListMap:   StringListToMap(whiteList),
 		ForceUpdate:    forceUpdate,
 		Debug:          debug,
 		Timeout:        timeout,
@@ -87,12 +114,12 @@ func New(srcGit, srcOrg, srcToken, dstGit, dstOrg, dstKey, dstToken, srcAccountT
 
 // prepare init src/dst APIs and Repos
 func (m *Mirror) prepare() error {
-	initAPI := func(t, accessToken string) (IGitAPI, error) {
+	initAPI := func(t, apiURL, uploadURL, accessToken string) (IGitAPI, error) {
 		switch t {
 		// init Github api Client
 		case constants.GITHUB:
-			logger.Infof("init %s API use accessToken(len: %d)", constants.GITHUB, len(accessToken))
-			client, err := NewGithubAPI(accessToken)
+			logger.Infof("init %s API %s use accessToken(len: %d)", constants.GITHUB, apiURL, len(accessToken))
+			client, err := NewGithubAPI(apiURL, uploadURL, accessToken)
 			if err != nil {
 				return nil, err
 			}
@@ -100,15 +127,59 @@ func (m *Mirror) prepare() error {
 
 		// init Gitee api Client
 		case constants.GITEE:
-			logger.Infof("init %s API use accessToken(len: %d)", constants.GITEE, len(accessToken))
-			client, err := NewGiteeAPI(accessToken)
+			logger.Infof("init %s API %s use accessToken(len: %d)", constants.GITEE, apiURL, len(accessToken))
+			client, err := NewGiteeAPI(apiURL, accessToken)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
+
+		// init GitLab api Client
+		case constants.GITLAB:
+			logger.Infof("init %s API %s use accessToken(len: %d)", constants.GITLAB, apiURL, len(accessToken))
+			client, err := NewGitLabAPI(apiURL, accessToken)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
+
+		// init Gitea api Client, codeberg.org is a public forgejo instance
+		case constants.GITEA, constants.FORGEJO, constants.CODEBERG:
+			if t == constants.CODEBERG && apiURL == "" {
+				apiURL = defaultCodebergBaseURL
+			}
+			logger.Infof("init %s API %s use accessToken(len: %d)", t, apiURL, len(accessToken))
+			client, err := NewGiteaAPI(apiURL, accessToken)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
+
+		// init Bitbucket Cloud api Client
+		case constants.BITBUCKET:
+			logger.Infof("init %s API %s use accessToken(len: %d)", t, apiURL, len(accessToken))
+			client, err := NewBitbucketAPI(apiURL, accessToken)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
+
+		// init Bitbucket Data Center api Client
+		case constants.BITBUCKETSERVER:
+			logger.Infof("init %s API %s use accessToken(len: %d)", t, apiURL, len(accessToken))
+			client, err := NewBitbucketServerAPI(apiURL, accessToken)
 			if err != nil {
 				return nil, err
 			}
 			return client, nil
 
+		// init local directory as git service
+		case constants.FILE:
+			logger.Infof("init %s API", t)
+			return NewFileAPI()
+
 		default:
-			return nil, fmt.Errorf("un-support git %s", m.SrcGit)
+			return nil, fmt.Errorf("un-support git %s", t)
 		}
 	}
 
@@ -142,56 +213,134 @@ func (m *Mirror) prepare() error {
 		}
 	}
 
-	initGitClient := func(keyPath, accessToken string) (*GitClient, error) {
+	initGitClient := func(t, keyPath, accessToken string) (*GitClient, error) {
 		if keyPath != "" {
 			logger.Infof("use ssh private key to init git client")
 			// maybe need to support ssh key with password
 			return NewGitPrivateKeysClient(keyPath, "", m.Timeout, m.Debug)
+		} else if username, password, ok := strings.Cut(accessToken, ":"); ok {
+			// like bitbucket `username:app_password`
+			logger.Infof("use username and password to init git client")
+			return NewGitUsernamePasswordClient(username, password, m.Timeout, m.Debug)
 		} else if accessToken != "" {
 			logger.Infof("use accessToken to init git client")
-			return NewGitAccessTokenClient(accessToken, m.Timeout, m.Debug)
+			return NewGitServiceAccessTokenClient(t, accessToken, m.Timeout, m.Debug)
 		} else {
 			logger.Infof("use empty auth to init git client")
-			return NewGitUsernamePasswordClient("", "", m.Timeout, m.Debug)
+			return NewGitNoneAuthClient(m.Timeout, m.Debug)
 		}
 	}
 
-	// init src
-	srcAPI, err := initAPI(m.SrcGit, m.srcToken)
+	// init black and white list
+	var err error
+	m.blackList, err = NewNameMatcher(m.BlackList)
+	if err != nil {
+		return fmt.Errorf("black-list: %s", err.Error())
+	}
+	m.whiteList, err = NewNameMatcher(m.WhiteList)
+	if err != nil {
+		return fmt.Errorf("white-list: %s", err.Error())
+	}
+	m.nameRules, err = NewNameRules(m.NameRules)
 	if err != nil {
 		return err
 	}
-	m.srcAPI = srcAPI
-
-	srcRepos, err := getRepos(m.SrcAccountType, m.SrcOrg, srcAPI)
+	m.refRenames, err = NewRefRenames(m.RefRenames)
+	if err != nil {
+		return err
+	}
+	m.extraRefs, err = NewExtraRefSpecs(m.ExtraRefs, m.DstGit)
 	if err != nil {
 		return err
 	}
-	m.srcRepos = srcRepos
-	m.srcReposMap = ReposToMap(srcRepos)
+	if m.RefFilter != nil {
+		if err = m.RefFilter.Validate(); err != nil {
+			return err
+		}
+	}
+	if m.Retry != nil {
+		if err = m.Retry.Validate(); err != nil {
+			return err
+		}
+	}
+	if err = m.validateOrphanPolicy(); err != nil {
+		return err
+	}
+
+	// init src
+	if m.SrcGit == constants.GIT {
+		// plain git, the repos come from the static list
+		srcPlainGit, err := NewPlainGit(m.SrcURLTemplate, m.SrcOrg, m.SrcRepos)
+		if err != nil {
+			return err
+		}
+		m.srcRepos = srcPlainGit.Repositories()
+	} else {
+		srcAPI, err := initAPI(m.SrcGit, m.SrcAPIURL, m.SrcUploadURL, m.srcToken)
+		if err != nil {
+			return err
+		}
+		srcRateLimitedAPI := newRateLimitedAPI(srcAPI, m.APIRate, m.Retry)
+		m.srcAPI = srcRateLimitedAPI
 
-	srcGitClient, err := initGitClient(m.dstKey, m.srcToken)
+		srcRepos, err := getRepos(m.SrcAccountType, m.SrcOrg, srcRateLimitedAPI)
+		if err != nil {
+			return err
+		}
+		m.srcRepos = srcRepos
+	}
+	m.srcReposMap = ReposToMap(m.srcRepos)
+
+	srcGitClient, err := initGitClient(m.SrcGit, m.dstKey, m.srcToken)
 	if err != nil {
 		return err
 	}
+	srcGitClient.ExtraRefSpecs = m.extraRefs
+	srcGitClient.Retry = m.Retry
+	if err = srcGitClient.SetBackend(m.GitBackend); err != nil {
+		return err
+	}
 	m.srcGitClient = srcGitClient
 
 	// init dst
-	dstAPI, err := initAPI(m.DstGit, m.dstToken)
-	if err != nil {
-		return err
+	if m.DstGit == constants.GIT {
+		// plain git, the repo urls come from the url template, and repos can not be listed
+		dstPlainGit, err := NewPlainGit(m.DstURLTemplate, m.DstOrg, nil)
+		if err != nil {
+			return err
+		}
+		m.dstPlainGit = dstPlainGit
+	} else {
+		dstAPI, err := initAPI(m.DstGit, m.DstAPIURL, m.DstUploadURL, m.dstToken)
+		if err != nil {
+			return err
+		}
+		dstRateLimitedAPI := newRateLimitedAPI(dstAPI, m.APIRate, m.Retry)
+		m.dstAPI = dstRateLimitedAPI
+
+		dstRepos, err := getRepos(m.DstAccountType, m.DstOrg, dstRateLimitedAPI)
+		if err != nil {
+			return err
+		}
+		m.dstRepos = dstRepos
 	}
-	m.dstAPI = dstAPI
+	m.dstReposMap = ReposToMap(m.dstRepos)
 
-	dstRepos, err := getRepos(m.DstAccountType, m.DstOrg, dstAPI)
+	var dstGitClient *GitClient
+	if m.DstGit == constants.FILE {
+		logger.Infof("use empty auth to init git client for file:// transport")
+		dstGitClient, err = NewGitNoneAuthClient(m.Timeout, m.Debug)
+	} else {
+		dstGitClient, err = initGitClient(m.DstGit, m.dstKey, m.dstToken)
+	}
 	if err != nil {
 		return err
 	}
-	m.dstRepos = dstRepos
-	m.dstReposMap = ReposToMap(dstRepos)
-
-	dstGitClient, err := initGitClient(m.dstKey, m.dstToken)
-	if err != nil {
+	dstGitClient.RefFilter = m.RefFilter
+	dstGitClient.RefRenames = m.refRenames
+	dstGitClient.ExtraRefSpecs = m.extraRefs
+	dstGitClient.Retry = m.Retry
+	if err = dstGitClient.SetBackend(m.GitBackend); err != nil {
 		return err
 	}
 	m.dstGitClient = dstGitClient
@@ -199,55 +348,62 @@ func (m *Mirror) prepare() error {
 	return nil
 }
 
-// isMirrorRepo check is mirror repo
-func (m *Mirror) isMirrorRepo(repoName string) bool {
-	if repoName == "" {
-		return false
+// getDstRepoName return the mapping of repo name, or transform it by name rules
+func (m *Mirror) getDstRepoName(repoName string) string {
+	if name, ok := m.Mappings[repoName]; ok {
+		return name
 	}
+	return m.nameRules.Apply(repoName)
+}
 
-	if len(m.BlackList) > 0 {
-		if _, ok := m.blackListMap[repoName]; ok {
-			return false
-		}
-	}
+// fullName return the full name of repo, like `github/xiexianbin/repo`, the absolute path org of file
+// is not separated twice, like `file/mnt/nas/backup/repo`
+func fullName(git, org, repoName string) string {
+	return git + "/" + strings.TrimPrefix(org, "/") + "/" + repoName
+}
 
-	return true
+// srcFullName return the full name of source repo, like `github/xiexianbin/repo`
+func (m *Mirror) srcFullName(repoName string) string {
+	return fullName(m.SrcGit, m.SrcOrg, repoName)
 }
 
-func (m *Mirror) getDstRepoName(repoName string) string {
-	if name, ok := m.Mappings[repoName]; ok {
-		return name
-	}
-	return repoName
+// dstFullName return the full name of destination repo
+func (m *Mirror) dstFullName(repoName string) string {
+	return fullName(m.DstGit, m.DstOrg, repoName)
 }
 
-// mirrorRepoInfo create or sync Repo Info
-func (m *Mirror) mirrorRepoInfo(srcRepo *Repository, dstRepoName string) (*Repository, error) {
+// repoInfoChanged check the repo info of dst repo need to update, dstRepo.Private != srcRepo.Private is ignored,
+// and the fields which dstGit can not store are ignored
+func repoInfoChanged(dstRepo, srcRepo *Repository, dstGit string) bool {
+	unsupported := unsupportedRepoFields[dstGit]
+	return !unsupported["homepage"] && !StringsEqual(dstRepo.Homepage, srcRepo.Homepage) ||
+		!unsupported["topics"] && dstRepo.Topics != nil && srcRepo.Topics != nil && len(dstRepo.Topics) != len(srcRepo.Topics) ||
+		!StringsEqual(dstRepo.Description, srcRepo.Description)
+}
+
+// mirrorRepoInfo create or sync Repo Info, return the dst repo and the updated metadata fields
+func (m *Mirror) mirrorRepoInfo(srcRepo *Repository, dstRepoName string) (*Repository, []string, error) {
 	var dstRepo *Repository
 	dstRepo, ok := m.dstReposMap[dstRepoName]
 	if ok {
-		// already created || dstRepo.Private != srcRepo.Private
-		if !StringsEqual(dstRepo.Homepage, srcRepo.Homepage) || len(dstRepo.Topics) != len(srcRepo.Topics) ||
-			!StringsEqual(dstRepo.Description, srcRepo.Description) {
+		// already created
+		if repoInfoChanged(dstRepo, srcRepo, m.DstGit) {
 			if client, ok := m.dstAPI.(IGitAPI); ok {
+				fields := updatedFields(dstRepo, srcRepo, m.DstGit)
 				dstRepo.Homepage = srcRepo.Homepage
 				dstRepo.Description = srcRepo.Description
 				dstRepo.Topics = srcRepo.Topics
 				dstRepo.Private = srcRepo.Private
 
-				var orgName string
-				if dstRepo.Organization == nil {
-					orgName = *dstRepo.Owner.Name
-				} else {
-					orgName = *dstRepo.Organization.Name
-				}
+				orgName := repoOwner(dstRepo)
 				_, err := client.UpdateRepository(orgName, *dstRepo.Name, dstRepo)
 				if err != nil {
 					logger.Warnf("update repo %s/%s err: %s", orgName, *dstRepo.Name, err.Error())
-					return dstRepo, nil
+					return dstRepo, nil, nil
 				}
+				return dstRepo, fields, nil
 			} else {
-				return nil, fmt.Errorf("git dstAPI is not implement interface IGitAPI.UpdateRepository")
+				return nil, nil, fmt.Errorf("git dstAPI is not implement interface IGitAPI.UpdateRepository")
 			}
 		}
 	} else {
@@ -260,112 +416,400 @@ func (m *Mirror) mirrorRepoInfo(srcRepo *Repository, dstRepoName string) (*Repos
 				Topics:      srcRepo.Topics,
 				Private:     srcRepo.Private,
 			}
-			return client.CreateRepository(dstRepo, m.DstOrg)
+			dstRepo, err := client.CreateRepository(dstRepo, m.DstOrg)
+			if err != nil {
+				return nil, nil, err
+			}
+			return dstRepo, []string{"created"}, nil
 		} else {
-			return nil, fmt.Errorf("git dstAPI is not implement interface IGitAPI.CreateRepository")
+			return nil, nil, fmt.Errorf("git dstAPI is not implement interface IGitAPI.CreateRepository")
 		}
 	}
 
-	return dstRepo, nil
+	return dstRepo, nil, nil
 }
 
-// mirrorGit clone/pull from src repo and push to dst repo
-func (m *Mirror) mirrorGit(srcRepo, dstRepo *Repository) error {
+// upToDate check the selected refs of src and dst repo by listing the remote refs, without fetch, the listed
+// refs are recorded in repoResult. the new created dst repo and empty src repo are not up-to-date
+func (m *Mirror) upToDate(srcRepo, dstRepo *Repository, repoResult *RepoResult) (bool, error) {
+	srcRefs, err := m.srcGitClient.ListRemoteRefs(GitURL(srcRepo, m.srcGitClient.GitAuthType))
+	if err != nil || len(srcRefs) == 0 {
+		return false, err
+	}
+	repoResult.srcRefs = srcRefs
+	dstRefs, err := m.dstGitClient.ListRemoteRefs(GitURL(dstRepo, m.dstGitClient.GitAuthType))
+	if err != nil || len(dstRefs) == 0 {
+		return false, err
+	}
+	repoResult.dstRefs = dstRefs
+
+	return m.dstGitClient.upToDate(srcRefs, dstRefs)
+}
+
+// mirrorGit clone/pull from src repo and push to dst repo, return the refs updated in dst repo and the fetched bytes
+func (m *Mirror) mirrorGit(srcRepo, dstRepo *Repository) (*PushResult, int64, error) {
 	var err error
 	// cachePath format: m.CachePath + "/" + m.SrcOrg + "/" + *srcRepo.Name
 	cachePath := path.Join(m.CachePath, m.SrcOrg, *srcRepo.Name)
+	cacheSize := packSize(cachePath)
 	// clone or fetch from origin
 	_, err = m.srcGitClient.CloneOrFetch(GitURL(srcRepo, m.srcGitClient.GitAuthType), "origin", cachePath)
 	if err != nil {
 		if errors.Is(err, transport.ErrEmptyRemoteRepository) {
 			logger.Warnf("source remote repository %s/%s is empty, skip.", *srcRepo.Owner.Name, *srcRepo.Name)
-			return nil
+			return nil, 0, ErrEmptyRepository
 		}
-		return err
+		return nil, 0, err
+	}
+	// the packs may be repacked smaller by gc
+	fetchedBytes := packSize(cachePath) - cacheSize
+	if fetchedBytes < 0 {
+		fetchedBytes = 0
 	}
 
 	// create dst git remote
 	err = m.dstGitClient.CreateRemote([]string{GitURL(dstRepo, m.dstGitClient.GitAuthType)}, m.DstGit, cachePath)
 	if err != nil {
-		return err
+		return nil, fetchedBytes, err
 	}
 
 	// push to dst
-	err = m.dstGitClient.Mirror(m.DstGit, cachePath, m.ForceUpdate)
+	pushResult, err := m.dstGitClient.Mirror(m.DstGit, cachePath, m.ForceUpdate)
 	if err != nil {
-		return err
+		return pushResult, fetchedBytes, err
 	}
 
-	return nil
+	return pushResult, fetchedBytes, nil
 }
 
-func (m *Mirror) mirror(srcRepo *Repository, dstRepoName string) error {
+// mirror the repo info and git commits, and record them in repoResult
+func (m *Mirror) mirror(srcRepo *Repository, dstRepoName string, repoResult *RepoResult) error {
 	var err error
 
-	// mirror repo infos
-	dstRepo, err := m.mirrorRepoInfo(srcRepo, dstRepoName)
+	// mirror repo infos, plain git has no repo info
+	var dstRepo *Repository
+	if m.dstPlainGit != nil {
+		dstRepo = m.dstPlainGit.Repository(dstRepoName)
+	} else {
+		dstRepo, repoResult.UpdatedFields, err = m.mirrorRepoInfo(srcRepo, dstRepoName)
+		if err != nil {
+			return err
+		}
+	}
+
+	// skip fetch and push if all the selected refs are up-to-date
+	upToDate, err := m.upToDate(srcRepo, dstRepo, repoResult)
 	if err != nil {
-		return err
+		logger.Warnf("check %s up-to-date err: %s", *srcRepo.Name, err.Error())
+	} else if upToDate {
+		logger.Infof("%s is up-to-date, skip fetch and push", *srcRepo.Name)
+		repoResult.Status = RepoUpToDate
+		return nil
 	}
 
 	// mirror git commits
-	err = m.mirrorGit(srcRepo, dstRepo)
+	pushResult, fetchedBytes, err := m.mirrorGit(srcRepo, dstRepo)
+	repoResult.FetchedBytes = fetchedBytes
+	if pushResult != nil {
+		repoResult.PushedRefs, repoResult.DeletedRefs = pushResult.Pushed, pushResult.Deleted
+	}
 	if err != nil {
 		return err
 	}
 
+	// snapshot the refs of dst after push for StateStore
+	if m.state != nil {
+		dstRefs, err := m.dstGitClient.ListRemoteRefs(GitURL(dstRepo, m.dstGitClient.GitAuthType))
+		if err != nil {
+			logger.Warnf("list %s refs err: %s", repoResult.DstFullName, err.Error())
+		}
+		repoResult.dstRefs = dstRefs
+	}
+
 	return nil
 }
 
-// Do mirror logic
-func (m *Mirror) Do() error {
-	// get src/dst Repos
-	err := m.prepare()
-	if err != nil {
-		return err
+// mirrorJob is a repo to mirror, index is the position of the repo in the white list or source repos
+type mirrorJob struct {
+	index       int
+	srcRepo     *Repository
+	dstRepoName string
+	isWhiteList bool
+}
+
+// jobs return the repos to mirror, and the results of all repos, which the skipped repos are filled.
+// the white-list keeps its order, exact name is mirrored even it is not in source, to report it;
+// black-list has higher priority than white-list
+func (m *Mirror) jobs() ([]*mirrorJob, []*RepoResult) {
+	// candidate repo, srcRepo is nil if the white-list repo is not in source
+	type candidate struct {
+		name          string
+		srcRepo       *Repository
+		whiteListRule string
 	}
 
-	var success, fail, skip int
-	if len(m.WhiteList) > 0 {
+	var candidates []*candidate
+	if len(m.whiteList) > 0 {
 		// mirror white list repos
-		total := len(m.WhiteList)
-		for i, srcRepoName := range m.WhiteList {
-			if srcRepo, ok := m.srcReposMap[srcRepoName]; ok {
-				dstRepoName := m.getDstRepoName(srcRepoName)
-				logger.Infof("(%d/%d) begin mirror WhiteList %s/%s/%s to %s/%s/%s",
-					i+1, total, m.SrcGit, m.SrcOrg, srcRepoName, m.DstGit, m.DstOrg, dstRepoName)
-				err := m.mirror(srcRepo, dstRepoName)
-				if err != nil {
-					logger.Errorf("(%d/%d) mirror occur err: %s", i+1, total, err.Error())
-					fail += 1
+		added := make(map[string]bool)
+		for _, p := range m.whiteList {
+			if p.isExact() {
+				if !added[p.raw] {
+					added[p.raw] = true
+					candidates = append(candidates, &candidate{name: p.raw, srcRepo: m.srcReposMap[p.raw], whiteListRule: p.raw})
 				}
-				success += 1
-			} else {
-				logger.Warnf("(%d/%d) source repo %s not in Org %s/%s, skip.", i+1, total, srcRepoName, m.SrcGit, m.SrcOrg)
-				skip += 1
+				continue
+			}
+
+			matched := 0
+			for _, srcRepo := range m.srcRepos {
+				if name := *srcRepo.Name; p.match(name) {
+					matched += 1
+					if !added[name] {
+						added[name] = true
+						candidates = append(candidates, &candidate{name: name, srcRepo: srcRepo, whiteListRule: p.raw})
+					}
+				}
+			}
+			if matched == 0 {
+				logger.Warnf("white-list rule %s matches no repo of Org %s/%s", p.raw, m.SrcGit, m.SrcOrg)
 			}
 		}
 	} else {
 		// mirror all repos
-		total := len(m.srcRepos)
-		for i, srcRepo := range m.srcRepos {
-			if m.isMirrorRepo(*srcRepo.Name) {
-				dstRepoName := m.getDstRepoName(*srcRepo.Name)
-				logger.Infof("(%d/%d) begin mirror %s/%s/%s to %s/%s/%s",
-					i+1, total, m.SrcGit, m.SrcOrg, *srcRepo.Name, m.DstGit, m.DstOrg, dstRepoName)
-				err := m.mirror(srcRepo, dstRepoName)
-				if err != nil {
-					logger.Errorf("(%d/%d) mirror occur err: %s", i+1, total, err.Error())
-					fail += 1
-				}
-				success += 1
-			} else {
-				logger.Warnf("(%d/%d) source repo %s of Org %s/%s maybe in black-list, skip.", i+1, total, *srcRepo.Name, m.SrcGit, m.SrcOrg)
-				skip += 1
+		for _, srcRepo := range m.srcRepos {
+			candidates = append(candidates, &candidate{name: *srcRepo.Name, srcRepo: srcRepo})
+		}
+	}
+
+	var jobs []*mirrorJob
+	now := time.Now()
+	total := len(candidates)
+	results := make([]*RepoResult, total)
+	for i, c := range candidates {
+		if c.srcRepo == nil {
+			logger.Warnf("(%d/%d) source repo %s not in Org %s/%s, skip.", i+1, total, c.name, m.SrcGit, m.SrcOrg)
+			results[i] = &RepoResult{SrcRepo: c.name, SrcFullName: m.srcFullName(c.name), Status: RepoSkipped, Reason: "not in source"}
+			continue
+		}
+		if rule, ok := m.blackList.Match(c.name); ok {
+			logger.Warnf("(%d/%d) source repo %s of Org %s/%s matches black-list rule %s, skip.", i+1, total, c.name, m.SrcGit, m.SrcOrg, rule)
+			results[i] = &RepoResult{SrcRepo: c.name, SrcFullName: m.srcFullName(c.name), Status: RepoSkipped,
+				Reason: "in black-list rule " + rule}
+			continue
+		}
+		if m.Filter != nil {
+			if reason, ok := m.Filter.Skip(c.srcRepo, now); ok {
+				logger.Warnf("(%d/%d) source repo %s of Org %s/%s %s, skip.", i+1, total, c.name, m.SrcGit, m.SrcOrg, reason)
+				results[i] = &RepoResult{SrcRepo: c.name, SrcFullName: m.srcFullName(c.name), Status: RepoSkipped, Reason: reason}
+				continue
 			}
 		}
+		if c.whiteListRule != "" && c.whiteListRule != c.name {
+			logger.Debugf("(%d/%d) source repo %s matches white-list rule %s", i+1, total, c.name, c.whiteListRule)
+		}
+		jobs = append(jobs, &mirrorJob{index: i, srcRepo: c.srcRepo, dstRepoName: m.getDstRepoName(c.name), isWhiteList: c.whiteListRule != ""})
 	}
-	logger.Printf("mirror done: success(%d) fail(%d) skip(%d)", success, fail, skip)
 
-	return nil
+	return m.checkDstRepoNames(jobs, results), results
+}
+
+// checkDstRepoNames fail the jobs whose destination name is empty or same as others, the git services
+// treat repo names case-insensitively, so the names are compared in lower-case
+func (m *Mirror) checkDstRepoNames(jobs []*mirrorJob, results []*RepoResult) []*mirrorJob {
+	dstJobs := make(map[string][]*mirrorJob, len(jobs))
+	for _, job := range jobs {
+		key := strings.ToLower(job.dstRepoName)
+		dstJobs[key] = append(dstJobs[key], job)
+	}
+
+	var validJobs []*mirrorJob
+	for _, job := range jobs {
+		var err error
+		if job.dstRepoName == "" {
+			err = fmt.Errorf("destination name of %s is empty", *job.srcRepo.Name)
+		} else if same := dstJobs[strings.ToLower(job.dstRepoName)]; len(same) > 1 {
+			names := make([]string, len(same))
+			for i, j := range same {
+				names[i] = *j.srcRepo.Name
+			}
+			err = fmt.Errorf("destination name %s collides, mapped from %s", job.dstRepoName, strings.Join(names, ", "))
+		}
+
+		if err != nil {
+			logger.Errorf("(%d/%d) %s, skip.", job.index+1, len(results), err.Error())
+			results[job.index] = &RepoResult{SrcRepo: *job.srcRepo.Name, DstRepo: job.dstRepoName,
+				SrcFullName: m.srcFullName(*job.srcRepo.Name), DstFullName: m.dstFullName(job.dstRepoName),
+				Status: RepoFailed, Err: err}
+			continue
+		}
+		validJobs = append(validJobs, job)
+	}
+
+	return validJobs
+}
+
+// prioritize sort the jobs by the states in StateStore, the failed and stale repos are mirrored first
+func (m *Mirror) prioritize(jobs []*mirrorJob) {
+	states := make(map[*mirrorJob]*RepoState, len(jobs))
+	for _, job := range jobs {
+		states[job] = m.state.Get(stateKey(m.srcFullName(*job.srcRepo.Name), m.dstFullName(job.dstRepoName)))
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return states[jobs[i]].before(states[jobs[j]])
+	})
+}
+
+// saveState record the outcome of repo in StateStore
+func (m *Mirror) saveState(repoResult *RepoResult) {
+	key := stateKey(repoResult.SrcFullName, repoResult.DstFullName)
+	state := &RepoState{}
+	if old := m.state.Get(key); old != nil {
+		*state = *old
+	}
+	state.update(repoResult, time.Now())
+	if err := m.state.Put(key, state); err != nil {
+		logger.Warnf("save state of %s err: %s", key, err.Error())
+	}
+}
+
+// apiQuotas return the rate limit quota of src and dst API, the APIs without quota are ignored
+func (m *Mirror) apiQuotas() []*APIQuota {
+	var quotas []*APIQuota
+	for _, api := range []struct {
+		name string
+		api  interface{}
+	}{{"src " + m.SrcGit + "/" + m.SrcOrg, m.srcAPI}, {"dst " + m.DstGit + "/" + m.DstOrg, m.dstAPI}} {
+		if q, ok := api.api.(quotaAPI); ok {
+			if quota := q.Quota(); quota != nil {
+				quota.Name = api.name
+				quotas = append(quotas, quota)
+			}
+		}
+	}
+	return quotas
+}
+
+// Do mirror logic, run Concurrency workers to mirror repos, and return the outcome of every repo.
+// err is only returned when the mirror can not start, the failed repos are in Result
+func (m *Mirror) Do() (*Result, error) {
+	// get src/dst Repos
+	err := m.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	jobs, results := m.jobs()
+	total := len(results)
+	concurrency := m.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > 1 {
+		logger.Infof("mirror %d repos with %d workers", len(jobs), concurrency)
+	}
+
+	// the state is not changed by dry-run
+	if !m.DryRun {
+		m.state, err = OpenStateStore(m.StateStore, m.CachePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if m.state != nil {
+		defer func() {
+			if err := m.state.Close(); err != nil {
+				logger.Errorf("close state store err: %s", err.Error())
+			}
+			m.state = nil
+		}()
+		m.prioritize(jobs)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var done int
+	sem := make(chan struct{}, concurrency)
+	for _, job := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(job *mirrorJob) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			srcRepoName := *job.srcRepo.Name
+			whiteList := ""
+			if job.isWhiteList {
+				whiteList = "WhiteList "
+			}
+			logger.Infof("(%d/%d) begin mirror %s%s/%s/%s to %s/%s/%s",
+				job.index+1, total, whiteList, m.SrcGit, m.SrcOrg, srcRepoName, m.DstGit, m.DstOrg, job.dstRepoName)
+			repoResult := &RepoResult{
+				SrcRepo:     srcRepoName,
+				DstRepo:     job.dstRepoName,
+				SrcFullName: m.srcFullName(srcRepoName),
+				DstFullName: m.dstFullName(job.dstRepoName),
+				Status:      RepoSuccess,
+			}
+			start := time.Now()
+			var err error
+			if m.DryRun {
+				err = m.plan(job.srcRepo, job.dstRepoName, repoResult)
+			} else {
+				err = m.mirror(job.srcRepo, job.dstRepoName, repoResult)
+			}
+			repoResult.Duration = time.Since(start)
+			if errors.Is(err, ErrEmptyRepository) {
+				repoResult.Status = RepoEmpty
+			} else if err != nil {
+				logger.Errorf("(%d/%d) mirror %s occur err: %s", job.index+1, total, srcRepoName, err.Error())
+				repoResult.Status, repoResult.Err = RepoFailed, err
+			}
+			if m.state != nil {
+				m.saveState(repoResult)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[job.index] = repoResult
+			done += 1
+			if concurrency > 1 {
+				logger.Infof("(%d/%d) mirror %s %s, progress %d/%d", job.index+1, total, srcRepoName, repoResult.Status, done, len(jobs))
+			}
+		}(job)
+	}
+	wg.Wait()
+
+	orphans := m.handleOrphans()
+	result := &Result{Duration: time.Since(start), DryRun: m.DryRun, APIQuotas: m.apiQuotas()}
+	for _, repoResult := range results {
+		result.add(repoResult)
+	}
+	for _, orphan := range orphans {
+		result.addOrphan(orphan)
+	}
+	if m.DryRun {
+		printPlan(result)
+	}
+	logger.Printf("mirror done: %s", result)
+	for _, quota := range result.APIQuotas {
+		logger.Printf("  %s", quota)
+	}
+
+	return result, nil
+}
+
+// FailedResult return the result of a mirror which can not start, like the source org is not found. the orgs
+// are recorded as a failed repo, so the failure is reported and counted by the fail-on policy
+func (m *Mirror) FailedResult(err error) *Result {
+	result := &Result{DryRun: m.DryRun}
+	result.add(&RepoResult{
+		SrcFullName: m.SrcGit + "/" + strings.TrimPrefix(m.SrcOrg, "/"),
+		DstFullName: m.DstGit + "/" + strings.TrimPrefix(m.DstOrg, "/"),
+		Status:      RepoFailed,
+		Err:         err,
+	})
+
+	return result
 }
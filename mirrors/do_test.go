@@ -0,0 +1,171 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+// newTestMirror return a Mirror from plain git source repos in tmp/src to file destination tmp/backup
+func newTestMirror(t *testing.T, tmp string, names ...string) *Mirror {
+	for _, name := range names {
+		newTestSourceRepo(t, filepath.Join(tmp, "src", name))
+	}
+
+	m := New(constants.GIT, "src", "", constants.FILE, filepath.Join(tmp, "backup"), "", "",
+		constants.AccountTypeUser, constants.AccountTypeUser, "ssh", filepath.Join(tmp, "cache"),
+		[]string{}, []string{}, false, false, defaultTimeOut, map[string]string{})
+	m.SrcURLTemplate = "file://" + filepath.ToSlash(tmp) + "/{org}/{name}"
+	m.SrcRepos = names
+
+	return m
+}
+
+func TestMirror_Do(t *testing.T) {
+	tmp := t.TempDir()
+	var names []string
+	for i := 0; i < 5; i++ {
+		names = append(names, fmt.Sprintf("repo%d", i))
+	}
+	m := newTestMirror(t, tmp, names...)
+	m.Concurrency = 3
+
+	result, err := m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success != len(names) || result.Failed != 0 {
+		t.Fatalf("unexpected result %s", result)
+	}
+	for _, repoResult := range result.Repos {
+		if len(repoResult.UpdatedFields) != 1 || repoResult.UpdatedFields[0] != "created" {
+			t.Errorf("expect %s is created, got %v", repoResult.SrcRepo, repoResult.UpdatedFields)
+		}
+		if len(repoResult.PushedRefs) == 0 || repoResult.FetchedBytes == 0 {
+			t.Errorf("expect %s is fetched and pushed, got %v", repoResult.SrcRepo, repoResult.PushedRefs)
+		}
+		if expected := "file" + filepath.ToSlash(filepath.Join(tmp, "backup", repoResult.SrcRepo)); repoResult.DstFullName != expected {
+			t.Errorf("expect dst full name %s, got %s", expected, repoResult.DstFullName)
+		}
+	}
+
+	for _, name := range names {
+		r, err := git.PlainOpen(filepath.Join(tmp, "backup", name+".git"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Reference("refs/heads/master", false); err != nil {
+			t.Fatalf("expect %s master is mirrored: %s", name, err)
+		}
+	}
+}
+
+func TestMirror_Do_Prune(t *testing.T) {
+	for _, backend := range []string{constants.GitBackendGoGit, constants.GitBackendCLI} {
+		t.Run(backend, func(t *testing.T) {
+			testMirrorPrune(t, backend)
+		})
+	}
+}
+
+func testMirrorPrune(t *testing.T, backend string) {
+	tmp := t.TempDir()
+	m := newTestMirror(t, tmp, "repo")
+	m.GitBackend = backend
+	srcRepo, _ := git.PlainOpen(filepath.Join(tmp, "src", "repo"))
+	head, _ := srcRepo.Head()
+	if err := srcRepo.Storer.SetReference(plumbing.NewHashReference("refs/heads/dev", head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Do(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the remote-tracking refs are not pushed, and the legacy ones are pruned
+	dstRepo, _ := git.PlainOpen(filepath.Join(tmp, "backup", "repo.git"))
+	refs, _ := dstRepo.References()
+	_ = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name().IsRemote() {
+			t.Errorf("expect remote-tracking ref %s is not pushed", ref.Name())
+		}
+		return nil
+	})
+	if err := dstRepo.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/master", head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+
+	// delete dev in source, it is pruned in cache and destination
+	if err := srcRepo.Storer.RemoveReference("refs/heads/dev"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted := result.Repos[0].DeletedRefs
+	if len(deleted) != 2 || deleted[0] != "refs/heads/dev" || deleted[1] != "refs/remotes/origin/master" {
+		t.Errorf("expect dev and legacy remote-tracking ref are deleted, got %v", deleted)
+	}
+	cacheRepo, _ := git.PlainOpen(filepath.Join(tmp, "cache", "src", "repo"))
+	if _, err := cacheRepo.Reference("refs/heads/dev", false); err == nil {
+		t.Error("expect dev is pruned in cache")
+	}
+}
+
+func TestMirror_Do_UpToDate(t *testing.T) {
+	tmp := t.TempDir()
+	m := newTestMirror(t, tmp, "repo")
+	if _, err := m.Do(); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing changed, fetch and push are skipped
+	cachePath := filepath.Join(tmp, "cache", "src", "repo")
+	if err := os.RemoveAll(cachePath); err != nil {
+		t.Fatal(err)
+	}
+	result, err := m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.UpToDate != 1 || result.Repos[0].Status != RepoUpToDate {
+		t.Fatalf("expect repo is up-to-date, got %s", result)
+	}
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Error("expect up-to-date repo is not fetched")
+	}
+
+	// new branch in source
+	srcRepo, _ := git.PlainOpen(filepath.Join(tmp, "src", "repo"))
+	head, _ := srcRepo.Head()
+	if err := srcRepo.Storer.SetReference(plumbing.NewHashReference("refs/heads/dev", head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+	result, err = m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success != 1 || len(result.Repos[0].PushedRefs) != 1 {
+		t.Errorf("expect dev is pushed, got %s %v", result, result.Repos[0].PushedRefs)
+	}
+}
@@ -19,3 +19,7 @@ import "fmt"
 func ErrNotFound(resource, name string) error {
 	return fmt.Errorf("resource %s %s not found", resource, name)
 }
+
+func ErrNotSupported(git, operation string) error {
+	return fmt.Errorf("%s does not support %s", git, operation)
+}
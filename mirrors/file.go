@@ -0,0 +1,222 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/xiexianbin/golib/logger"
+)
+
+const (
+	fileRepoSuffix     = ".git"
+	fileMetadataSuffix = ".json"
+)
+
+// FileAPI use a local directory of bare repositories as git service, the org name is the directory path,
+// every repo is stored in `<path>/<name>.git`, and the repo metadata is stored in sidecar file `<path>/<name>.json`
+type FileAPI struct{}
+
+// NewFileAPI return new File API
+func NewFileAPI() (*FileAPI, error) {
+	return &FileAPI{}, nil
+}
+
+// IsAPIAuthed return false, the directory do not need auth
+func (f *FileAPI) IsAPIAuthed() bool {
+	return false
+}
+
+// Organizations return the directory itself
+func (f *FileAPI) Organizations(user string) ([]*Organization, error) {
+	org, err := f.GetOrganization(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*Organization{org}, nil
+}
+
+// GetOrganization return the directory as Organization
+func (f *FileAPI) GetOrganization(orgName string) (*Organization, error) {
+	info, err := os.Stat(orgName)
+	if err != nil || !info.IsDir() {
+		return nil, ErrNotFound("Organization", orgName)
+	}
+
+	orgType := "directory"
+	return &Organization{Name: &orgName, Type: &orgType}, nil
+}
+
+// Repositories list all bare repositories in directory user
+func (f *FileAPI) Repositories(user string) ([]*Repository, error) {
+	return f.RepositoriesByOrg(user)
+}
+
+// GetRepository read the repository and its metadata
+func (f *FileAPI) GetRepository(orgName, repoName string) (*Repository, error) {
+	repoPath := filepath.Join(orgName, repoName+fileRepoSuffix)
+	if _, err := git.PlainOpen(repoPath); err != nil {
+		return nil, ErrNotFound("Repository", repoPath)
+	}
+
+	repo := &Repository{}
+	content, err := os.ReadFile(filepath.Join(orgName, repoName+fileMetadataSuffix))
+	if err == nil {
+		if err := json.Unmarshal(content, repo); err != nil {
+			return nil, fmt.Errorf("decode %s/%s metadata err: %s", orgName, repoName, err.Error())
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return formatFileRepo(orgName, repoName, repo)
+}
+
+// CreateRepository init a bare repository and write its metadata, if repo is already exist, just return it
+func (f *FileAPI) CreateRepository(baseRepo *Repository, orgName string) (*Repository, error) {
+	if baseRepo.Name == nil || *baseRepo.Name == "" {
+		return nil, fmt.Errorf("new repo name must not be empty")
+	}
+	if repo, err := f.GetRepository(orgName, *baseRepo.Name); err == nil {
+		return repo, nil
+	}
+
+	repoPath := filepath.Join(orgName, *baseRepo.Name+fileRepoSuffix)
+	logger.Infof("[git init --bare %s]", repoPath)
+	if err := os.MkdirAll(orgName, 0755); err != nil {
+		return nil, err
+	}
+	if _, err := git.PlainInit(repoPath, true); err != nil {
+		return nil, fmt.Errorf("init bare repository %s err: %s", repoPath, err.Error())
+	}
+
+	return f.UpdateRepository(orgName, *baseRepo.Name, baseRepo)
+}
+
+// UpdateRepository write the metadata of repository to the sidecar file
+func (f *FileAPI) UpdateRepository(orgName, repoName string, baseRepo *Repository) (*Repository, error) {
+	metadata := &Repository{
+		Name:        &repoName,
+		Description: baseRepo.Description,
+		Homepage:    baseRepo.Homepage,
+		Topics:      baseRepo.Topics,
+		Private:     baseRepo.Private,
+		Archived:    baseRepo.Archived,
+	}
+	content, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	metadataPath := filepath.Join(orgName, repoName+fileMetadataSuffix)
+	if err := os.WriteFile(metadataPath, content, 0644); err != nil {
+		return nil, fmt.Errorf("write metadata %s err: %s", metadataPath, err.Error())
+	}
+
+	return f.GetRepository(orgName, repoName)
+}
+
+// DeleteRepository remove the bare repository and its metadata
+func (f *FileAPI) DeleteRepository(orgName, repoName string) error {
+	if _, err := f.GetRepository(orgName, repoName); err != nil {
+		return err
+	}
+
+	repoPath := filepath.Join(orgName, repoName+fileRepoSuffix)
+	logger.Infof("[rm -rf %s]", repoPath)
+	if err := os.RemoveAll(repoPath); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(orgName, repoName+fileMetadataSuffix)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ArchiveRepository mark the repository archived in its metadata
+func (f *FileAPI) ArchiveRepository(orgName, repoName string) error {
+	repo, err := f.GetRepository(orgName, repoName)
+	if err != nil {
+		return err
+	}
+
+	archived := true
+	repo.Archived = &archived
+	_, err = f.UpdateRepository(orgName, repoName, repo)
+	return err
+}
+
+// RepositoriesByOrg list all bare repositories in directory orgName, if the directory is not exist, return empty
+func (f *FileAPI) RepositoriesByOrg(orgName string) ([]*Repository, error) {
+	entries, err := os.ReadDir(orgName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []*Repository{}, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasSuffix(entry.Name(), fileRepoSuffix) {
+			names = append(names, strings.TrimSuffix(entry.Name(), fileRepoSuffix))
+		}
+	}
+	sort.Strings(names)
+
+	baseRepos := make([]*Repository, 0, len(names))
+	for _, name := range names {
+		repo, err := f.GetRepository(orgName, name)
+		if err != nil {
+			logger.Warnf("skip %s: %s", filepath.Join(orgName, name+fileRepoSuffix), err.Error())
+			continue
+		}
+		baseRepos = append(baseRepos, repo)
+	}
+
+	return baseRepos, nil
+}
+
+// formatFileRepo fill the name, owner and file:// urls of repo
+func formatFileRepo(orgName, repoName string, repo *Repository) (*Repository, error) {
+	absPath, err := filepath.Abs(filepath.Join(orgName, repoName+fileRepoSuffix))
+	if err != nil {
+		return nil, err
+	}
+	url := "file://" + filepath.ToSlash(absPath)
+	fullName := orgName + "/" + repoName
+
+	repo.Owner = &User{
+		Name: &orgName,
+	}
+	repo.Name = &repoName
+	repo.FullName = &fullName
+	repo.CloneURL = &url
+	repo.GitURL = &url
+	repo.SSHURL = &url
+	if repo.Topics == nil {
+		repo.Topics = []string{}
+	}
+
+	return repo, nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-github/github"
+)
+
+// newTestSourceRepo init a repository in path with one commit on master
+func newTestSourceRepo(t *testing.T, path string) *git.Repository {
+	r, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "README.md"), []byte("# test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, _ := r.Worktree()
+	if _, err := w.Add("README.md"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Commit("init", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestFileAPI_CreateRepository(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backup")
+	f, _ := NewFileAPI()
+
+	repos, err := f.RepositoriesByOrg(dir)
+	if err != nil || len(repos) != 0 {
+		t.Fatalf("expect empty repos of not exist dir, got %d, err: %v", len(repos), err)
+	}
+
+	_, err = f.CreateRepository(&Repository{
+		Name:        github.String("repo"),
+		Description: github.String("i am description."),
+		Topics:      []string{"mirror"},
+		Private:     github.Bool(true),
+	}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := git.PlainOpen(filepath.Join(dir, "repo.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _ := r.Config(); !cfg.Core.IsBare {
+		t.Fatal("expect bare repository")
+	}
+
+	_, err = f.UpdateRepository(dir, "repo", &Repository{Description: github.String("updated")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos, err = f.RepositoriesByOrg(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || *repos[0].Description != "updated" || *repos[0].Owner.Name != dir {
+		t.Fatalf("unexpected repos %v", repos)
+	}
+}
+
+func TestFileAPI_Mirror(t *testing.T) {
+	tmp := t.TempDir()
+	srcPath := filepath.Join(tmp, "src")
+	newTestSourceRepo(t, srcPath)
+
+	f, _ := NewFileAPI()
+	dstRepo, err := f.CreateRepository(&Repository{Name: github.String("repo")}, filepath.Join(tmp, "backup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := NewGitNoneAuthClient(defaultTimeOut, false)
+	cachePath := filepath.Join(tmp, "cache")
+	if _, err := c.CloneOrFetch("file://"+filepath.ToSlash(srcPath), "origin", cachePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateRemote([]string{GitURL(dstRepo, c.GitAuthType)}, "file", cachePath); err != nil {
+		t.Fatal(err)
+	}
+	pushResult, err := c.Mirror("file", cachePath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pushResult.Pushed) == 0 || pushResult.Pushed[0] != "refs/heads/master" {
+		t.Fatalf("expect master is pushed, got %v", pushResult.Pushed)
+	}
+
+	r, _ := git.PlainOpen(filepath.Join(tmp, "backup", "repo.git"))
+	if _, err := r.Reference("refs/heads/master", false); err != nil {
+		t.Fatalf("expect master is mirrored: %s", err)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+// RepoFilter select the source repos by their metadata, the repo is not skipped by fork, archived, visibility,
+// topics, language or push time if the field is unknown, like the repos of plain git. the topics are unknown
+// if nil, which the git service can not report, like gitee and bitbucket
+type RepoFilter struct {
+	SkipForks     bool
+	SkipArchived  bool
+	Visibility    string        // public, private or all
+	RequireTopics []string      // the repo must have all the topics
+	Languages     []string      // the repo language must be one of them, case-insensitive
+	PushedSince   time.Duration // the repo must be pushed in the duration, 0 is no limit
+}
+
+// Validate check the visibility
+func (f *RepoFilter) Validate() error {
+	switch f.Visibility {
+	case "", constants.VisibilityAll, constants.VisibilityPublic, constants.VisibilityPrivate:
+		return nil
+	default:
+		return fmt.Errorf("un-support visibility %s", f.Visibility)
+	}
+}
+
+// Skip return the reason if the repo is not selected
+func (f *RepoFilter) Skip(repo *Repository, now time.Time) (string, bool) {
+	if f.SkipForks && repo.Fork != nil && *repo.Fork {
+		return "is fork", true
+	}
+	if f.SkipArchived && repo.Archived != nil && *repo.Archived {
+		return "is archived", true
+	}
+	if repo.Private != nil {
+		if f.Visibility == constants.VisibilityPublic && *repo.Private {
+			return "is private", true
+		}
+		if f.Visibility == constants.VisibilityPrivate && !*repo.Private {
+			return "is public", true
+		}
+	}
+
+	for _, topic := range f.RequireTopics {
+		if repo.Topics == nil {
+			break
+		}
+		found := false
+		for _, t := range repo.Topics {
+			if strings.EqualFold(t, topic) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "without topic " + topic, true
+		}
+	}
+
+	if len(f.Languages) > 0 && repo.Language != nil && *repo.Language != "" {
+		found := false
+		for _, language := range f.Languages {
+			if strings.EqualFold(*repo.Language, language) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "language is " + *repo.Language, true
+		}
+	}
+
+	if f.PushedSince > 0 && repo.PushedAt != nil && now.Sub(*repo.PushedAt) > f.PushedSince {
+		return "not pushed since " + repo.PushedAt.Format(time.RFC3339), true
+	}
+
+	return "", false
+}
+
+// ParseSince parse the duration like `90d`, `2w` or the go duration like `36h`
+func ParseSince(str string) (time.Duration, error) {
+	if str == "" {
+		return 0, nil
+	}
+
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(str, suffix); ok {
+			if days, err := strconv.Atoi(n); err == nil && days >= 0 {
+				return time.Duration(days) * unit, nil
+			}
+			return 0, fmt.Errorf("invalid duration %s", str)
+		}
+	}
+
+	d, err := time.ParseDuration(str)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %s", str)
+	}
+	return d, nil
+}
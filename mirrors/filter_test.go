@@ -0,0 +1,111 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"testing"
+	"time"
+
+	gitee "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/google/go-github/github"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+func TestRepoFilter_Skip(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-100 * 24 * time.Hour)
+	filter := &RepoFilter{
+		SkipForks:     true,
+		SkipArchived:  true,
+		Visibility:    constants.VisibilityPublic,
+		RequireTopics: []string{"mirror"},
+		Languages:     []string{"go"},
+		PushedSince:   90 * 24 * time.Hour,
+	}
+
+	tests := []struct {
+		name       string
+		repo       *Repository
+		wantReason string
+	}{
+		{"selected", &Repository{Topics: []string{"Mirror"}, Language: github.String("Go"), Private: github.Bool(false), PushedAt: &now}, ""},
+		{"fork", &Repository{Fork: github.Bool(true)}, "is fork"},
+		{"archived", &Repository{Archived: github.Bool(true)}, "is archived"},
+		{"private", &Repository{Private: github.Bool(true)}, "is private"},
+		{"topic", &Repository{Topics: []string{"test"}}, "without topic mirror"},
+		{"language", &Repository{Topics: []string{"mirror"}, Language: github.String("Java")}, "language is Java"},
+		{"pushed", &Repository{Topics: []string{"mirror"}, PushedAt: &old}, "not pushed since " + old.Format(time.RFC3339)},
+		{"unknown", &Repository{Topics: []string{"mirror"}}, ""},
+		{"no topics", &Repository{Topics: []string{}}, "without topic mirror"},
+		{"unknown topics", &Repository{}, ""},
+		{"plain git", (&PlainGit{Org: "org"}).Repository("repo"), ""},
+		{"bitbucket", formatBitbucketRepo(&bitbucketRepository{Slug: "repo"}), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := filter.Skip(tt.repo, now)
+			if reason != tt.wantReason || ok != (tt.wantReason != "") {
+				t.Errorf("Skip() = %s, %v, want %s", reason, ok, tt.wantReason)
+			}
+		})
+	}
+
+	if err := (&RepoFilter{Visibility: "internal"}).Validate(); err == nil {
+		t.Error("expect un-support visibility err")
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		str     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"90d", 90 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"xd", 0, true},
+		{"-1h", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSince(tt.str)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseSince(%s) = %s, %v, want %s", tt.str, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormatRepo_Selection(t *testing.T) {
+	pushedAt := time.Date(2022, 5, 28, 14, 40, 22, 0, time.UTC)
+	repo := formatGithubRepo(&github.Repository{
+		Owner:    &github.User{Login: github.String("xiexianbin")},
+		Language: github.String("Go"),
+		PushedAt: &github.Timestamp{Time: pushedAt},
+	})
+	if *repo.Language != "Go" || !repo.PushedAt.Equal(pushedAt) {
+		t.Errorf("unexpected github repo %v %v", repo.Language, repo.PushedAt)
+	}
+
+	repo = formatGiteeRepo(gitee.Project{
+		Owner:    &gitee.UserBasic{Login: "xiexianbin"},
+		Language: "Go",
+		PushedAt: "2022-05-28T22:40:22+08:00",
+	})
+	if *repo.Language != "Go" || !repo.PushedAt.Equal(pushedAt) {
+		t.Errorf("unexpected gitee repo %v %v", repo.Language, repo.PushedAt)
+	}
+}
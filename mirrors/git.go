@@ -19,6 +19,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -28,7 +30,10 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/xiexianbin/golib/logger"
+
+	"github.com/x-actions/git-mirrors/constants"
 )
 
 type GitAuthType int
@@ -37,13 +42,20 @@ const (
 	GitKeyAuth GitAuthType = iota
 	GitAccessTokenAuth
 	GitUsernamePasswordAuth
+	GitNoneAuth
 )
 
-var defaultPushRefSpecs = []config.RefSpec{
+// defaultMirrorRefSpecs is the namespaces mirrored from source to the cache repo, and from the cache repo to
+// destination, like `git clone --mirror` and `git push --mirror`. the other namespaces like `refs/notes/*` are
+// opt-in by ExtraRefSpecs, so the forge managed refs like `refs/pull/*` are not fetched by default.
+// `refs/remotes/*` is never pushed, the remote-tracking refs are not part of the mirror
+var defaultMirrorRefSpecs = []config.RefSpec{
 	"refs/heads/*:refs/heads/*",
-	"refs/remotes/*:refs/remotes/*",
 	"refs/tags/*:refs/tags/*"}
 
+// legacyPushedNamespace is pushed to destination by the old versions, it is cleaned up by prune
+const legacyPushedNamespace = "refs/remotes/"
+
 type GitClient struct {
 	auth         transport.AuthMethod
 	cloneOptions *git.CloneOptions
@@ -52,6 +64,15 @@ type GitClient struct {
 	pushOptions  *git.PushOptions
 	Timeout      time.Duration
 	GitAuthType  GitAuthType
+	RefFilter    *RefFilter // the branches and tags to push and prune, nil is all
+	RefRenames   RefRenames // the rules to rename refs in destination
+	// the refspecs of extra ref namespaces to fetch and push, like `refs/notes/*:refs/notes/*`
+	ExtraRefSpecs []config.RefSpec
+	Retry         *RetryPolicy // retry the transient failures of Clone, Fetch and Mirror, nil is no retry
+
+	backend        GitBackend
+	privateKeyFile string // the ssh private key used by the git cli backend
+	debug          bool
 }
 
 // NewGitPrivateKeysClient ssh key auth
@@ -92,9 +113,12 @@ func NewGitPrivateKeysClient(privateKeyFile, keyPassword string, timeout time.Du
 			Auth:            publicKey,
 			InsecureSkipTLS: true,
 		},
-		Timeout:     timeout,
-		GitAuthType: GitKeyAuth,
+		Timeout:        timeout,
+		GitAuthType:    GitKeyAuth,
+		privateKeyFile: privateKeyFile,
 	}
+	client.backend = &goGitBackend{client}
+	client.debug = debug
 	if debug {
 		client.cloneOptions.Progress = os.Stdout
 		client.pullOptions.Progress = os.Stdout
@@ -155,6 +179,8 @@ func httpBasicAuthClient(username, password string, timeout time.Duration, authT
 		Timeout:     timeout,
 		GitAuthType: authType,
 	}
+	client.backend = &goGitBackend{client}
+	client.debug = debug
 	if debug {
 		client.cloneOptions.Progress = os.Stdout
 		client.pullOptions.Progress = os.Stdout
@@ -164,20 +190,62 @@ func httpBasicAuthClient(username, password string, timeout time.Duration, authT
 	return client, nil
 }
 
+// accessTokenUsernames are the usernames of https git with access token required by git services,
+// the others accept any username
+var accessTokenUsernames = map[string]string{
+	// https://support.atlassian.com/bitbucket-cloud/docs/using-access-tokens/
+	constants.BITBUCKET: "x-token-auth",
+}
+
 // NewGitAccessTokenClient access_token auth
 func NewGitAccessTokenClient(accessToken string, timeout time.Duration, debug bool) (*GitClient, error) {
 	return httpBasicAuthClient("", accessToken, timeout, GitAccessTokenAuth, debug)
 }
 
+// NewGitServiceAccessTokenClient access_token auth with the username required by git service t, like bitbucket
+func NewGitServiceAccessTokenClient(t, accessToken string, timeout time.Duration, debug bool) (*GitClient, error) {
+	return httpBasicAuthClient(accessTokenUsernames[t], accessToken, timeout, GitAccessTokenAuth, debug)
+}
+
 // NewGitUsernamePasswordClient username password auth
 func NewGitUsernamePasswordClient(username, password string, timeout time.Duration, debug bool) (*GitClient, error) {
 	return httpBasicAuthClient(username, password, timeout, GitUsernamePasswordAuth, debug)
 }
 
-// Clone clone git to local directory
-func (c *GitClient) Clone(url, path string) error {
+// NewGitNoneAuthClient without auth, like the file:// transport
+func NewGitNoneAuthClient(timeout time.Duration, debug bool) (*GitClient, error) {
+	client := &GitClient{
+		cloneOptions: &git.CloneOptions{},
+		pullOptions:  &git.PullOptions{},
+		fetchOptions: &git.FetchOptions{},
+		pushOptions:  &git.PushOptions{},
+		Timeout:      timeout,
+		GitAuthType:  GitNoneAuth,
+	}
+	client.backend = &goGitBackend{client}
+	client.debug = debug
+	if debug {
+		client.cloneOptions.Progress = os.Stdout
+		client.pullOptions.Progress = os.Stdout
+		client.fetchOptions.Progress = os.Stdout
+		client.pushOptions.Progress = os.Stdout
+	}
+	return client, nil
+}
+
+// Clone clone git to local directory as bare repository, without worktree
+func (c *goGitBackend) Clone(url, path string) error {
+	return c.clone(url, path, true)
+}
+
+// clone clone git to local directory, isBare defines if the repository will have worktree
+func (c *goGitBackend) clone(url, path string, isBare bool) error {
 	// Clone the given repository to the given path
-	logger.Infof("[git clone %s] in path %s", url, path)
+	if isBare {
+		logger.Infof("[git clone --bare %s] in path %s", url, path)
+	} else {
+		logger.Infof("[git clone %s] in path %s", url, path)
+	}
 	o := *c.cloneOptions
 	o.URL = url
 	//o.RemoteName = "origin"
@@ -188,7 +256,7 @@ func (c *GitClient) Clone(url, path string) error {
 		<-time.After(c.Timeout)
 		cancel()
 	}()
-	_, err := git.PlainCloneContext(ctx, path, false, &o)
+	_, err := git.PlainCloneContext(ctx, path, isBare, &o)
 	if err != nil {
 		// if is "remote repository is empty" err, skip
 		//if errors.Is(err, transport.ErrEmptyRemoteRepository) {
@@ -254,12 +322,20 @@ func (c *GitClient) CloneOrPull(url, remoteName, path string) (bool, error) {
 		}
 		return false, c.Pull(remoteName, path)
 	} else {
-		return true, c.Clone(url, path)
+		return true, (&goGitBackend{c}).clone(url, path, false)
 	}
 }
 
-// Fetch git repo changes to local directory
-func (c *GitClient) Fetch(remoteName, path string) error {
+// mirrorRefSpecs return the refspecs of namespaces to mirror
+func (c *GitClient) mirrorRefSpecs() []config.RefSpec {
+	return append(append([]config.RefSpec{}, defaultMirrorRefSpecs...), c.ExtraRefSpecs...)
+}
+
+// Fetch mirror the selected namespaces of remote to local directory, the refs are force updated, and the refs
+// deleted in remote are pruned, equal git cmd:
+//
+//	git fetch --prune --force remoteName "refs/heads/*:refs/heads/*" "refs/tags/*:refs/tags/*"
+func (c *goGitBackend) Fetch(remoteName, path string) error {
 	if remoteName == "" {
 		remoteName = "origin"
 	}
@@ -273,8 +349,9 @@ func (c *GitClient) Fetch(remoteName, path string) error {
 	logger.Infof("[git fetch %s] in path %s", remoteName, path)
 	o := *c.fetchOptions
 	o.RemoteName = remoteName
-	o.RefSpecs = []config.RefSpec{"refs/*:refs/*"}
-	o.Tags = git.TagFollowing
+	o.RefSpecs = c.mirrorRefSpecs()
+	o.Force = true
+	o.Tags = git.NoTags
 	//err = w.Pull(&o)
 	// pull with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
@@ -283,25 +360,78 @@ func (c *GitClient) Fetch(remoteName, path string) error {
 		cancel()
 	}()
 	err = r.FetchContext(ctx, &o)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+			return err
+		}
+		return fmt.Errorf("[git fetch %s] in path %s err: %w", remoteName, path, err)
+	}
+
+	// fetch with prune is not support in go-git v5.4.2, delete the local refs which are deleted in remote
+	remoteRefs, err := c.listRemoteRefs(r, remoteName)
 	if err != nil {
-		if errors.Is(err, git.NoErrAlreadyUpToDate) {
-			return nil
+		return err
+	}
+	return pruneLocalRefs(r, o.RefSpecs, remoteRefs)
+}
+
+// pruneLocalRefs delete the local refs fetched by refSpecs, which are not in remote any more
+func pruneLocalRefs(repo *git.Repository, refSpecs []config.RefSpec, remoteRefs map[string]string) error {
+	fetched := make(map[plumbing.ReferenceName]bool, len(remoteRefs))
+	for name := range remoteRefs {
+		for _, refSpec := range refSpecs {
+			if refSpec.Match(plumbing.ReferenceName(name)) {
+				fetched[refSpec.Dst(plumbing.ReferenceName(name))] = true
+				break
+			}
 		}
-		if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+	var stale []plumbing.ReferenceName
+	_ = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && !fetched[ref.Name()] && matchDst(refSpecs, ref.Name()) {
+			stale = append(stale, ref.Name())
+		}
+		return nil
+	})
+	for _, name := range stale {
+		logger.Debugf("prune local ref %s, which is deleted in remote", name)
+		if err := repo.Storer.RemoveReference(name); err != nil {
 			return err
 		}
-		return fmt.Errorf("[git fetch %s] in path %s err: %s", remoteName, path, err.Error())
 	}
+
 	return nil
 }
 
-// CloneOrFetch if path is not exist run git clone, else fetch
+// matchDst check the name match the dst side of any refSpecs
+func matchDst(refSpecs []config.RefSpec, name plumbing.ReferenceName) bool {
+	for _, refSpec := range refSpecs {
+		if refSpec.Reverse().Match(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CloneOrFetch if path is not exist run git clone, else fetch. the path is bare repository,
+// the non-bare one created by old versions is migrated to bare
 func (c *GitClient) CloneOrFetch(url, remoteName, path string) (bool, error) {
 	if remoteName == "" {
 		remoteName = "origin"
 	}
 
-	_, err := git.PlainOpen(path)
+	err := migrateToBare(path)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = git.PlainOpen(path)
 	if err == nil {
 		err = c.CreateRemote([]string{url}, remoteName, path)
 		if err != nil {
@@ -318,6 +448,46 @@ func (c *GitClient) CloneOrFetch(url, remoteName, path string) (bool, error) {
 	}
 }
 
+// migrateToBare convert the non-bare repository in path to bare: move `.git` out, remove the worktree,
+// then move it back as path. the interrupted migration is continued by the next call
+func migrateToBare(path string) error {
+	tmp := path + ".bare"
+	dotGit := filepath.Join(path, git.GitDirName)
+	if fi, err := os.Stat(dotGit); err == nil && fi.IsDir() {
+		// the leftover of interrupted migration is stale, as `.git` is still in path
+		if err := os.RemoveAll(tmp); err != nil {
+			return err
+		}
+		if err := os.Rename(dotGit, tmp); err != nil {
+			return fmt.Errorf("migrate %s to bare repository err: %s", path, err.Error())
+		}
+	} else if _, err := os.Stat(tmp); err != nil {
+		// bare repository or not exist
+		return nil
+	}
+
+	logger.Infof("migrate %s to bare repository", path)
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("remove worktree of %s err: %s", path, err.Error())
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("migrate %s to bare repository err: %s", path, err.Error())
+	}
+	_ = os.Remove(filepath.Join(path, "index"))
+
+	r, err := git.PlainOpen(path)
+	if err != nil {
+		return fmt.Errorf("open migrated repository %s err: %s", path, err.Error())
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	cfg.Core.IsBare = true
+
+	return r.SetConfig(cfg)
+}
+
 // DeleteRemote delete special remote
 func (c *GitClient) DeleteRemote(remoteName, path string) error {
 	r, err := git.PlainOpen(path)
@@ -330,7 +500,7 @@ func (c *GitClient) DeleteRemote(remoteName, path string) error {
 
 // CreateRemote create remote
 // url eg. https://github.com/git-fixtures/basic.git
-func (c *GitClient) CreateRemote(urls []string, remoteName, path string) error {
+func (c *goGitBackend) CreateRemote(urls []string, remoteName, path string) error {
 	r, err := git.PlainOpen(path)
 	if err != nil {
 		return fmt.Errorf("create remote, when open git repository from path %s err: %s", path, err.Error())
@@ -378,188 +548,168 @@ func (c *GitClient) CreateRemote(urls []string, remoteName, path string) error {
 	return nil
 }
 
-// findRemoteBranchesAndTag
-func (c *GitClient) findRemoteBranchesAndTag(repo *git.Repository, remoteName string) (map[string]string, map[string]string, error) {
-	//repoTags := make(map[string]string)
-	//repoBranches := make(map[string]string)
-	//// all Tags
-	//tags, err := repo.Tags()
-	//if err != nil {
-	//	return err
-	//}
-	//_ = tags.ForEach(func(tag *plumbing.Reference) error {
-	//	repoTags[tag.Name().String()] = tag.Hash().String()
-	//	return nil
-	//})
-	//
-	//// all Branches
-	//branches, err := repo.Branches()
-	//if err != nil {
-	//	return err
-	//}
-	//_ = branches.ForEach(func(branch *plumbing.Reference) error {
-	//	repoBranches[branch.Name().String()] = branch.Hash().String()
-	//	return nil
-	//})
+// PushResult is the refs updated by Mirror in the remote
+type PushResult struct {
+	Pushed  []string `json:"pushed,omitempty"`  // refs created or updated
+	Deleted []string `json:"deleted,omitempty"` // refs deleted by fixPrune
+}
+
+// listRemoteRefs list the hash references of remote, the empty remote has no references
+func (c *GitClient) listRemoteRefs(repo *git.Repository, remoteName string) (map[string]string, error) {
 	remote, err := repo.Remote(remoteName)
 	if err != nil {
-		return nil, nil, err
-	}
-	if remote == nil {
-		return nil, nil, fmt.Errorf("can not find remote %s", remoteName)
+		return nil, err
 	}
 
-	// List the references on the remote repository
 	refs, err := remote.List(&git.ListOptions{
 		Auth:            c.auth,
 		InsecureSkipTLS: false,
 	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("list remote %s the references on the remote repository err: %s", remoteName, err.Error())
+		if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("list remote %s the references on the remote repository err: %s", remoteName, err.Error())
 	}
 
-	tags := make(map[string]string)
-	branches := make(map[string]string)
+	hashes := make(map[string]string)
 	for _, remoteRef := range refs {
-		// skip InvalidReference and SymbolicReference
 		if remoteRef.Type() == plumbing.HashReference {
-			if remoteRef.Name().IsBranch() {
-				branches[remoteRef.Name().String()] = remoteRef.Hash().String()
-			} else if remoteRef.Name().IsTag() {
-				tags[remoteRef.Name().String()] = remoteRef.Hash().String()
-			}
+			hashes[remoteRef.Name().String()] = remoteRef.Hash().String()
 		}
 	}
 
-	return branches, tags, nil
+	return hashes, nil
 }
 
-// deleteRefs delete local refs
-func deleteRefs(repo *git.Repository, deleteName string) error {
-	lastStr := func(str string) string {
-		temp := strings.Split(str, "/")
-		return temp[len(temp)-1]
-	}
-
+// pushTargets return the remote refs of local refs pushed by refSpecs, map the remote name to local hash
+func pushTargets(repo *git.Repository, refSpecs []config.RefSpec) (map[string]string, error) {
 	refs, err := repo.References()
-	deleteShortName := lastStr(deleteName)
+	if err != nil {
+		return nil, err
+	}
 
-	_ = refs.ForEach(func(ref *plumbing.Reference) error {
-		// ref.Name().Short() = dev2 | gitee/dev2
-		shortName := lastStr(ref.Name().Short())
-		if shortName == deleteShortName {
-			err = repo.Storer.RemoveReference(ref.Name())
-			if err != nil {
-				return err
+	targets := make(map[string]string)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		for _, refSpec := range refSpecs {
+			if refSpec.Match(ref.Name()) {
+				targets[refSpec.Dst(ref.Name()).String()] = ref.Hash().String()
+				break
 			}
 		}
 		return nil
 	})
 
-	return err
+	return targets, err
 }
 
-// fixPrune fix Push with Prune does not achieve the desired effect
-// ref: https://github.com/go-git/go-git/issues/172 bug
-func (c *GitClient) fixPrune(repo *git.Repository, srcRemoteName, dstRemoteName, path string) error {
-	// Src Remote: get remote by srcRemoteName
-	srcRemoteBranches, srcRemoteTags, err := c.findRemoteBranchesAndTag(repo, srcRemoteName)
-	if err != nil {
-		return err
+// splitBranchesAndTag split the refs to branches and tags, the other refs are dropped
+func splitBranchesAndTag(refs map[string]string) (map[string]string, map[string]string) {
+	tags := make(map[string]string)
+	branches := make(map[string]string)
+	for name, hash := range refs {
+		refName := plumbing.ReferenceName(name)
+		if refName.IsBranch() {
+			branches[name] = hash
+		} else if refName.IsTag() {
+			tags[name] = hash
+		}
 	}
 
-	// Src Remote: get remote by dstRemoteName
-	if dstRemoteName == "" {
-		dstRemoteName = "origin"
-	}
-	dstRemoteBranches, dstRemoteTags, err := c.findRemoteBranchesAndTag(repo, dstRemoteName)
-	if err != nil {
-		return err
-	}
+	return branches, tags
+}
 
-	var delRefSpecs []config.RefSpec
+// ListRemote list the hash references of remote url without local repository, like `git ls-remote`
+func (c *goGitBackend) ListRemote(url string) (map[string]string, error) {
+	repo, err := git.Init(memory.NewStorage(), nil)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{url}}); err != nil {
+		return nil, err
 	}
 
-	// find which branch to del, and del it in local repo
-	for name, dstHash := range dstRemoteBranches {
-		srcHash, ok := srcRemoteBranches[name]
-		if ok && dstHash == srcHash {
-			continue
-		}
+	return c.listRemoteRefs(repo, "origin")
+}
 
-		delRefSpecs = append(delRefSpecs, config.RefSpec(fmt.Sprintf(":%s", name)))
-		err = deleteRefs(repo, name)
-		//err := c.DeleteBranch(name, path, repo)
-		if err != nil {
-			return err
-		}
-	}
+// refsToPrune return the remote refs in the mirrored namespaces and the legacy `refs/remotes/*`, which are not
+// the targets pushed from local, the refs excluded by RefFilter are kept unless PruneExcluded
+func (c *GitClient) refsToPrune(targets, remoteRefs map[string]string) []string {
+	mirrorRefSpecs := c.mirrorRefSpecs()
 
-	// find which tags to del, and del it in local repo
-	for name, dstHash := range dstRemoteTags {
-		srcHash, ok := srcRemoteTags[name]
-		if ok && dstHash == srcHash {
+	var refs []string
+	for name := range remoteRefs {
+		refName := plumbing.ReferenceName(name)
+		if !matchDst(mirrorRefSpecs, refName) && !strings.HasPrefix(name, legacyPushedNamespace) {
 			continue
 		}
-
-		delRefSpecs = append(delRefSpecs, config.RefSpec(fmt.Sprintf(":%s", name)))
-		//_ = deleteRefs(repo, refs, name)
-	}
-
-	// push diffRefs
-	if len(delRefSpecs) > 0 {
-		delRefSpecsList := make([]string, len(delRefSpecs))
-		for i, ref := range delRefSpecs {
-			delRefSpecsList[i] = ref.String()
+		if c.RefFilter.shouldPrune(refName, targets) {
+			refs = append(refs, name)
 		}
-		logger.Infof("try to delete refs [%s]", strings.Join(delRefSpecsList, ", "))
+	}
+	sort.Strings(refs)
 
-		remote, err := repo.Remote(dstRemoteName)
-		if err != nil {
-			return err
-		}
-		if remote == nil {
-			return fmt.Errorf("can not find remote %s in path %s", dstRemoteName, path)
-		}
+	return refs
+}
 
-		o := *c.pushOptions
-		o.RemoteName = dstRemoteName
-		o.RefSpecs = delRefSpecs
+// fixPrune fix Push with Prune does not achieve the desired effect, delete the refsToPrune of remote,
+// equal `git push --prune`.
+// ref: https://github.com/go-git/go-git/issues/172 bug
+// return the deleted refs of dst remote
+func (c *goGitBackend) fixPrune(repo *git.Repository, remoteName, path string, targets, remoteRefs map[string]string) ([]string, error) {
+	deleted := c.refsToPrune(targets, remoteRefs)
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+	logger.Infof("try to delete refs [%s]", strings.Join(deleted, ", "))
 
-		// push
-		if err := remote.Push(&o); err != nil {
-			if errors.Is(err, git.NoErrAlreadyUpToDate) {
-				return nil
-			} else {
-				return fmt.Errorf("fixPrune %s in path %s occur err: %s", dstRemoteName, path, err.Error())
-			}
-		}
+	delRefSpecs := make([]config.RefSpec, len(deleted))
+	for i, name := range deleted {
+		delRefSpecs[i] = config.RefSpec(fmt.Sprintf(":%s", name))
+	}
 
-		// fetch dst remote
-		if err := c.Fetch(dstRemoteName, path); err != nil {
-			logger.Errorf("fixPrune %s in path %s occur err: %s", dstRemoteName, path, err.Error())
-			return err
-		}
+	o := *c.pushOptions
+	o.RemoteName = remoteName
+	o.RefSpecs = delRefSpecs
+	if err := repo.Push(&o); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("fixPrune %s in path %s occur err: %s", remoteName, path, err.Error())
 	}
 
-	return nil
+	return deleted, nil
 }
 
-// Push open a repository in a specific path, and push to its remoteName remote.
+// Mirror open a repository in a specific path, and push the mirrored namespaces to its remoteName remote,
+// return the refs updated in remote.
 // equal git cmd:
 //
-//	git push --prune --tags [--force] [origin|gitee|github] "refs/*:refs/*" #refs/remotes/origin/*:refs/heads/*
-func (c *GitClient) Mirror(remoteName, path string, force bool) error {
+//	git push --prune [--force] [origin|gitee|github] "refs/heads/*:refs/heads/*" "refs/tags/*:refs/tags/*"
+func (c *goGitBackend) Mirror(remoteName, path string, force bool) (*PushResult, error) {
 	if remoteName == "" {
 		remoteName = "origin"
 	}
 
 	r, err := git.PlainOpen(path)
 	if err != nil {
-		return fmt.Errorf("when open git repository from path %s err: %s", path, err.Error())
+		return nil, fmt.Errorf("when open git repository from path %s err: %s", path, err.Error())
+	}
+
+	// compare local refs with remote refs before push, to find which refs would be pushed
+	refSpecs, err := pushRefSpecs(r, c.mirrorRefSpecs(), c.RefFilter, c.RefRenames)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := pushTargets(r, refSpecs)
+	if err != nil {
+		return nil, err
+	}
+	remoteRefs, err := c.listRemoteRefs(r, remoteName)
+	if err != nil {
+		return nil, err
 	}
+	pushed := diffRefs(targets, remoteRefs)
 
 	if !force {
 		logger.Infof("[git push %s] in path %s", remoteName, path)
@@ -568,42 +718,38 @@ func (c *GitClient) Mirror(remoteName, path string, force bool) error {
 	}
 	o := *c.pushOptions
 	o.RemoteName = remoteName
-	// run:
-	//   git show-ref
-	//   git remote -v
 	// Push with Prune does not achieve the desired effect, ref: https://github.com/go-git/go-git/issues/172
-	o.RefSpecs = defaultPushRefSpecs
-	//o.RefSpecs = []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"}
+	o.RefSpecs = refSpecs
 	o.Prune = false
 	o.Force = force
 
-	//err = r.Push(&o)
 	// push with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
 	go func() {
 		<-time.After(c.Timeout)
 		cancel()
 	}()
-	err = r.PushContext(ctx, &o)
-	if err != nil {
+	if len(refSpecs) == 0 {
+		logger.Warnf("no refs selected to push remoteName %s. path: %s", remoteName, path)
+	} else if len(pushed) == 0 {
+		logger.Debugf("push remoteName %s. path: %s, already up-to-date", remoteName, path)
+	} else if err = r.PushContext(ctx, &o); err != nil {
 		if errors.Is(err, git.NoErrAlreadyUpToDate) {
 			logger.Debugf("push remoteName %s. path: %s, already up-to-date", remoteName, path)
+			pushed = nil
 		} else {
-			return fmt.Errorf("push remoteName: %s, path: %s, err: %s", remoteName, path, err.Error())
+			return nil, fmt.Errorf("push remoteName: %s, path: %s, err: %w", remoteName, path, err)
 		}
 	}
+	result := &PushResult{Pushed: pushed}
 
 	// in https://github.com/go-git/go-git/blob/v5.4.2/COMPATIBILITY.md prune in not support in v5.4.2
-	err = c.fixPrune(r, "origin", remoteName, path)
+	result.Deleted, err = c.fixPrune(r, remoteName, path, targets, remoteRefs)
 	if err != nil {
-		if errors.Is(err, git.NoErrAlreadyUpToDate) {
-			logger.Debugf("fix prune push remoteName %s. path: %s, already up-to-date", remoteName, path)
-		} else {
-			return err
-		}
+		return result, err
 	}
 
-	return nil
+	return result, nil
 }
 
 // DeleteBranch delete special branch
@@ -0,0 +1,223 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/xiexianbin/golib/logger"
+)
+
+// cliBackend run git operations by the git binary, which supports protocol v2 and is faster on large packs.
+// the refs to push and prune are computed same as goGitBackend, by reading the local repository with go-git
+type cliBackend struct {
+	*GitClient
+}
+
+// env return the environment of git command, the credentials are passed by environment instead of arguments,
+// so they are not shown in the process list. the TLS certificates are always verified
+func (c *cliBackend) env() []string {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var configs [][2]string
+	if auth, ok := c.auth.(*http.BasicAuth); ok {
+		token := base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))
+		configs = append(configs, [2]string{"http.extraHeader", "Authorization: Basic " + token})
+	}
+	if len(configs) > 0 {
+		env = append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(configs)))
+		for i, kv := range configs {
+			env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, kv[0]), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, kv[1]))
+		}
+	}
+	if c.GitAuthType == GitKeyAuth {
+		env = append(env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i '%s' -o IdentitiesOnly=yes", c.privateKeyFile))
+	}
+
+	return env
+}
+
+// run git command in dir, return the stdout
+func (c *cliBackend) run(dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	logger.Debugf("[git %s] in path %s", strings.Join(args, " "), dir)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = c.env()
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if c.debug {
+		cmd.Stderr = io.MultiWriter(&stderr, os.Stdout)
+	}
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("[git %s] in path %s timeout after %s", args[0], dir, c.Timeout)
+		}
+		return "", fmt.Errorf("[git %s] in path %s err: %s, %s", args[0], dir, err.Error(), strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// Clone clone git to local directory as bare repository
+func (c *cliBackend) Clone(url, path string) error {
+	logger.Infof("[git clone --bare %s] in path %s", url, path)
+	_, err := c.run("", "clone", "--bare", "--quiet", url, path)
+	return err
+}
+
+// Fetch mirror the selected namespaces of remote to local directory, equal git cmd:
+//
+//	git fetch --prune --force --no-tags remoteName "refs/heads/*:refs/heads/*" "refs/tags/*:refs/tags/*"
+func (c *cliBackend) Fetch(remoteName, path string) error {
+	logger.Infof("[git fetch %s] in path %s", remoteName, path)
+	args := []string{"fetch", "--prune", "--force", "--no-tags", "--quiet", remoteName}
+	for _, refSpec := range c.mirrorRefSpecs() {
+		args = append(args, refSpec.String())
+	}
+	if _, err := c.run(path, args...); err != nil {
+		return err
+	}
+
+	// same as go-git, the remote without any mirrored refs is empty
+	r, err := git.PlainOpen(path)
+	if err != nil {
+		return fmt.Errorf("open git repository from path %s err: %s", path, err.Error())
+	}
+	targets, err := pushTargets(r, c.mirrorRefSpecs())
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return transport.ErrEmptyRemoteRepository
+	}
+
+	return nil
+}
+
+// CreateRemote create remote, or update its url
+func (c *cliBackend) CreateRemote(urls []string, remoteName, path string) error {
+	url, err := c.run(path, "remote", "get-url", remoteName)
+	if err == nil {
+		if strings.TrimSpace(url) == urls[0] {
+			return nil
+		}
+		logger.Infof("[git remote set-url %s %s]", remoteName, urls[0])
+		_, err = c.run(path, "remote", "set-url", remoteName, urls[0])
+		return err
+	}
+
+	logger.Infof("[git remote add %s %s]", remoteName, urls[0])
+	_, err = c.run(path, "remote", "add", remoteName, urls[0])
+	return err
+}
+
+// lsRemote list the hash references of remote, which is a remote name in path or an url
+func (c *cliBackend) lsRemote(remote, path string) (map[string]string, error) {
+	out, err := c.run(path, "ls-remote", remote)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		hash, name, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok || name == plumbing.HEAD.String() || strings.HasSuffix(name, "^{}") {
+			continue
+		}
+		refs[name] = hash
+	}
+
+	return refs, scanner.Err()
+}
+
+// ListRemote list the hash references of remote url without local repository
+func (c *cliBackend) ListRemote(url string) (map[string]string, error) {
+	return c.lsRemote(url, "")
+}
+
+// Mirror push the mirrored namespaces to remoteName remote, then delete the refsToPrune, equal git cmd:
+//
+//	git push [--force] remoteName "refs/heads/*:refs/heads/*" "refs/tags/*:refs/tags/*"
+//	git push remoteName --delete refs...
+func (c *cliBackend) Mirror(remoteName, path string, force bool) (*PushResult, error) {
+	r, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, fmt.Errorf("when open git repository from path %s err: %s", path, err.Error())
+	}
+
+	refSpecs, err := pushRefSpecs(r, c.mirrorRefSpecs(), c.RefFilter, c.RefRenames)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := pushTargets(r, refSpecs)
+	if err != nil {
+		return nil, err
+	}
+	remoteRefs, err := c.lsRemote(remoteName, path)
+	if err != nil {
+		return nil, err
+	}
+	result := &PushResult{Pushed: diffRefs(targets, remoteRefs)}
+
+	if len(refSpecs) == 0 {
+		logger.Warnf("no refs selected to push remoteName %s. path: %s", remoteName, path)
+	} else if len(result.Pushed) == 0 {
+		logger.Debugf("push remoteName %s. path: %s, already up-to-date", remoteName, path)
+	} else {
+		args := []string{"push", "--quiet"}
+		if !force {
+			logger.Infof("[git push %s] in path %s", remoteName, path)
+		} else {
+			logger.Warnf("[git push %s -f] in path %s", remoteName, path)
+			args = append(args, "--force")
+		}
+		args = append(args, remoteName)
+		for _, refSpec := range refSpecs {
+			args = append(args, refSpec.String())
+		}
+		if _, err := c.run(path, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	deleted := c.refsToPrune(targets, remoteRefs)
+	if len(deleted) > 0 {
+		logger.Infof("try to delete refs [%s]", strings.Join(deleted, ", "))
+		if _, err := c.run(path, append([]string{"push", "--quiet", remoteName, "--delete"}, deleted...)...); err != nil {
+			return result, err
+		}
+		result.Deleted = deleted
+	}
+
+	return result, nil
+}
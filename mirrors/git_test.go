@@ -15,8 +15,15 @@
 package mirrors
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"github.com/x-actions/git-mirrors/constants"
 )
 
 const (
@@ -42,6 +49,18 @@ func TestNewAccessTokenClient(t *testing.T) {
 	}
 }
 
+func TestNewGitServiceAccessTokenClient(t *testing.T) {
+	for service, expected := range map[string]string{constants.BITBUCKET: "x-token-auth", constants.GITLAB: "xiexianbin"} {
+		c, err := NewGitServiceAccessTokenClient(service, "token", defaultTimeOut, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth, ok := c.auth.(*http.BasicAuth); !ok || auth.Username != expected || auth.Password != "token" {
+			t.Errorf("expect username %s of %s, got %+v", expected, service, c.auth)
+		}
+	}
+}
+
 func TestGitClient_CloneOrPull(t *testing.T) {
 	var err error
 	//c, err := NewGitUsernamePasswordClient("", "")
@@ -104,8 +123,63 @@ func TestGitClient_Mirror(t *testing.T) {
 		return
 	}
 
-	err = c.Mirror("gitee", TempPath, false)
+	_, err = c.Mirror("gitee", TempPath, false)
 	if err != nil {
 		t.Skip(err)
 	}
 }
+
+func TestGitClient_CloneOrFetch_Bare(t *testing.T) {
+	tmp := t.TempDir()
+	newTestSourceRepo(t, filepath.Join(tmp, "src"))
+	url := "file://" + filepath.ToSlash(filepath.Join(tmp, "src"))
+	c, _ := NewGitNoneAuthClient(defaultTimeOut, false)
+
+	isBare := func(path string) bool {
+		r, err := git.PlainOpen(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, _ := r.Config()
+		_, err = os.Stat(filepath.Join(path, "README.md"))
+		return cfg.Core.IsBare && os.IsNotExist(err)
+	}
+
+	// new cache is bare
+	cachePath := filepath.Join(tmp, "cache")
+	if _, err := c.CloneOrFetch(url, "origin", cachePath); err != nil {
+		t.Fatal(err)
+	}
+	if !isBare(cachePath) {
+		t.Error("expect new cache is bare")
+	}
+
+	// the worktree cache of old versions is migrated
+	oldPath := filepath.Join(tmp, "old")
+	if _, err := git.PlainClone(oldPath, false, &git.CloneOptions{URL: url}); err != nil {
+		t.Fatal(err)
+	}
+	isNewClone, err := c.CloneOrFetch(url, "origin", oldPath)
+	if err != nil || isNewClone {
+		t.Fatalf("expect old cache is fetched, new clone %v, err: %v", isNewClone, err)
+	}
+	if !isBare(oldPath) {
+		t.Error("expect old cache is migrated to bare")
+	}
+
+	// mirror from the bare cache
+	dst := filepath.Join(tmp, "dst.git")
+	if _, err := git.PlainInit(dst, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateRemote([]string{"file://" + filepath.ToSlash(dst)}, "file", oldPath); err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.Mirror("file", oldPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Pushed) != 1 || result.Pushed[0] != "refs/heads/master" {
+		t.Errorf("expect master is pushed, got %v", result.Pushed)
+	}
+}
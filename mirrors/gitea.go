@@ -0,0 +1,362 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Gitea v1 REST API, also used by Forgejo and Codeberg
+
+package mirrors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/xiexianbin/golib/logger"
+)
+
+const (
+	// maxGiteaPerPage is the default [api] MAX_RESPONSE_ITEMS of gitea
+	maxGiteaPerPage        = 50
+	defaultCodebergBaseURL = "https://codeberg.org/api/v1"
+)
+
+type giteaUser struct {
+	ID       int    `json:"id"`
+	Login    string `json:"login"`
+	UserName string `json:"username"`
+}
+
+type giteaOrganization struct {
+	ID          int    `json:"id"`
+	UserName    string `json:"username"`
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+}
+
+type giteaRepository struct {
+	ID          int        `json:"id"`
+	Owner       *giteaUser `json:"owner"`
+	Name        string     `json:"name"`
+	FullName    string     `json:"full_name"`
+	Description string     `json:"description"`
+	HTMLURL     string     `json:"html_url"`
+	CloneURL    string     `json:"clone_url"`
+	SSHURL      string     `json:"ssh_url"`
+	Website     string     `json:"website"`
+	Fork        bool       `json:"fork"`
+	Private     bool       `json:"private"`
+	Archived    bool       `json:"archived"`
+	Topics      []string   `json:"topics"`
+	Language    string     `json:"language"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+}
+
+type GiteaAPI struct {
+	Client      *restClient
+	Context     context.Context
+	accessToken string
+	IsAuthed    bool
+}
+
+// NewGiteaAPI return new Gitea API, baseURL is like https://gitea.example.com/api/v1
+func NewGiteaAPI(baseURL, accessToken string) (*GiteaAPI, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("gitea api url must not be empty")
+	}
+	ctx := context.Background()
+	client := newRESTClient(ctx, baseURL, accessToken)
+
+	return &GiteaAPI{Client: client, Context: ctx, accessToken: accessToken, IsAuthed: accessToken != ""}, nil
+}
+
+// IsAPIAuthed return is the API auth, true or false
+func (g *GiteaAPI) IsAPIAuthed() bool {
+	return g.IsAuthed
+}
+
+// Organizations list Organizations of user, if user is empty list the authenticated user's
+func (g *GiteaAPI) Organizations(user string) ([]*Organization, error) {
+	path := "/user/orgs"
+	if user != "" {
+		path = fmt.Sprintf("/users/%s/orgs", url.PathEscape(user))
+	}
+
+	page := 1
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(maxGiteaPerPage))
+
+	var baseOrgs []*Organization
+	for {
+		query.Set("page", strconv.Itoa(page))
+		var orgs []*giteaOrganization
+		if _, err := g.Client.do(http.MethodGet, path, query, nil, &orgs); err != nil {
+			return nil, err
+		}
+		for _, org := range orgs {
+			baseOrgs = append(baseOrgs, formatGiteaOrg(org))
+		}
+
+		if len(orgs) < maxGiteaPerPage {
+			break
+		}
+
+		page += 1
+	}
+
+	return baseOrgs, nil
+}
+
+// GetOrganization get an organization by name
+func (g *GiteaAPI) GetOrganization(orgName string) (*Organization, error) {
+	if orgName == "" {
+		return nil, fmt.Errorf("organization name must not be empty")
+	}
+
+	var org giteaOrganization
+	resp, err := g.Client.do(http.MethodGet, "/orgs/"+url.PathEscape(orgName), nil, nil, &org)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return nil, ErrNotFound("Organization", orgName)
+		}
+		return nil, err
+	}
+	logger.Debugf("get gitea %s Organization: %v", orgName, org)
+
+	return formatGiteaOrg(&org), nil
+}
+
+func (g *GiteaAPI) listRepos(path string) ([]*Repository, error) {
+	page := 1
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(maxGiteaPerPage))
+
+	var baseRepos []*Repository
+	for {
+		query.Set("page", strconv.Itoa(page))
+		var repos []*giteaRepository
+		resp, err := g.Client.do(http.MethodGet, path, query, nil, &repos)
+		if err != nil {
+			if isStatus(resp, http.StatusNotFound) {
+				return nil, ErrNotFound("Owner", path)
+			}
+			return nil, err
+		}
+		for _, repo := range repos {
+			baseRepos = append(baseRepos, formatGiteaRepo(repo))
+		}
+
+		if len(repos) < maxGiteaPerPage {
+			break
+		}
+
+		page += 1
+	}
+
+	return baseRepos, nil
+}
+
+// Repositories list all repositories for the authenticated user, if user is special, list the user's repositories
+func (g *GiteaAPI) Repositories(user string) ([]*Repository, error) {
+	if user == "" {
+		return g.listRepos("/user/repos")
+	}
+
+	return g.listRepos(fmt.Sprintf("/users/%s/repos", url.PathEscape(user)))
+}
+
+// GetRepository fetches a repository
+func (g *GiteaAPI) GetRepository(orgName, repoName string) (*Repository, error) {
+	var repo giteaRepository
+	resp, err := g.Client.do(http.MethodGet, giteaRepoPath(orgName, repoName), nil, nil, &repo)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return nil, ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return nil, err
+	}
+
+	return formatGiteaRepo(&repo), nil
+}
+
+// CreateRepository create a new repository, if orgName is an organization create it in the organization,
+// else for the authenticated user. if repo is already exist, just return it
+func (g *GiteaAPI) CreateRepository(baseRepo *Repository, orgName string) (*Repository, error) {
+	if baseRepo.Name == nil || *baseRepo.Name == "" {
+		return nil, fmt.Errorf("new repo name must not be empty")
+	}
+
+	body := map[string]interface{}{
+		"name": *baseRepo.Name,
+	}
+	if baseRepo.Description != nil {
+		body["description"] = *baseRepo.Description
+	}
+	if baseRepo.Private != nil {
+		body["private"] = *baseRepo.Private
+	}
+
+	path := "/user/repos"
+	if _, err := g.GetOrganization(orgName); err == nil {
+		path = fmt.Sprintf("/orgs/%s/repos", url.PathEscape(orgName))
+	}
+
+	var repo giteaRepository
+	resp, err := g.Client.do(http.MethodPost, path, nil, body, &repo)
+	if err != nil {
+		if isStatus(resp, http.StatusConflict) {
+			// 409 The repository with the same name already exists.
+			if baseRepo, err := g.GetRepository(orgName, *baseRepo.Name); err == nil {
+				return baseRepo, nil
+			}
+		}
+		return nil, err
+	}
+
+	// website and topics can not be set when create
+	if (baseRepo.Homepage != nil && *baseRepo.Homepage != "") || len(baseRepo.Topics) > 0 {
+		return g.UpdateRepository(repo.Owner.Login, repo.Name, baseRepo)
+	}
+
+	return formatGiteaRepo(&repo), nil
+}
+
+// UpdateRepository updates a repository and its topics
+func (g *GiteaAPI) UpdateRepository(orgName, repoName string, baseRepo *Repository) (*Repository, error) {
+	body := map[string]interface{}{}
+	if baseRepo.Description != nil {
+		body["description"] = *baseRepo.Description
+	}
+	if baseRepo.Homepage != nil {
+		body["website"] = *baseRepo.Homepage
+	}
+	if baseRepo.Private != nil {
+		body["private"] = *baseRepo.Private
+	}
+
+	var repo giteaRepository
+	resp, err := g.Client.do(http.MethodPatch, giteaRepoPath(orgName, repoName), nil, body, &repo)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return nil, ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return nil, err
+	}
+
+	if baseRepo.Topics != nil {
+		err := g.ReplaceTopics(orgName, repoName, baseRepo.Topics)
+		if err != nil {
+			return nil, err
+		}
+		repo.Topics = baseRepo.Topics
+	}
+
+	return formatGiteaRepo(&repo), nil
+}
+
+// DeleteRepository delete a repository
+func (g *GiteaAPI) DeleteRepository(orgName, repoName string) error {
+	resp, err := g.Client.do(http.MethodDelete, giteaRepoPath(orgName, repoName), nil, nil, nil)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return err
+	}
+	return nil
+}
+
+// ArchiveRepository archive a repository, it becomes read-only
+func (g *GiteaAPI) ArchiveRepository(orgName, repoName string) error {
+	body := map[string]interface{}{"archived": true}
+	resp, err := g.Client.do(http.MethodPatch, giteaRepoPath(orgName, repoName), nil, body, nil)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return err
+	}
+	return nil
+}
+
+// ReplaceTopics replace all topics of a repository
+func (g *GiteaAPI) ReplaceTopics(orgName, repoName string, topics []string) error {
+	body := map[string]interface{}{
+		"topics": topics,
+	}
+	_, err := g.Client.do(http.MethodPut, giteaRepoPath(orgName, repoName)+"/topics", nil, body, nil)
+	if err != nil {
+		return fmt.Errorf("replace %s/%s topics err: %s", orgName, repoName, err.Error())
+	}
+
+	return nil
+}
+
+// RepositoriesByOrg list repositories for special org
+func (g *GiteaAPI) RepositoriesByOrg(orgName string) ([]*Repository, error) {
+	repos, err := g.listRepos(fmt.Sprintf("/orgs/%s/repos", url.PathEscape(orgName)))
+	if err != nil {
+		return nil, fmt.Errorf("list gitea org %s repos err: %s", orgName, err.Error())
+	}
+
+	return repos, nil
+}
+
+func giteaRepoPath(orgName, repoName string) string {
+	return fmt.Sprintf("/repos/%s/%s", url.PathEscape(orgName), url.PathEscape(repoName))
+}
+
+func formatGiteaOrg(org *giteaOrganization) *Organization {
+	orgType := "Organization"
+	baseOrg := &Organization{
+		Name:        &org.UserName,
+		Description: &org.Description,
+		Type:        &orgType,
+	}
+
+	return baseOrg
+}
+
+func formatGiteaRepo(repo *giteaRepository) *Repository {
+	topics := repo.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+
+	baseRepo := &Repository{
+		Name:        &repo.Name,
+		FullName:    &repo.FullName,
+		Description: &repo.Description,
+		HTMLURL:     &repo.HTMLURL,
+		CloneURL:    &repo.CloneURL,
+		GitURL:      &repo.SSHURL,
+		SSHURL:      &repo.SSHURL,
+		Homepage:    &repo.Website,
+		Fork:        &repo.Fork,
+		Topics:      topics,
+		Language:    &repo.Language,
+		Private:     &repo.Private,
+		Archived:    &repo.Archived,
+		PushedAt:    repo.UpdatedAt,
+	}
+
+	if repo.Owner != nil {
+		baseRepo.Owner = &User{
+			Name: &repo.Owner.Login,
+		}
+	}
+
+	return baseRepo
+}
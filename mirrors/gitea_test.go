@@ -0,0 +1,225 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// giteaTestServer is a in memory stand-in of the gitea v1 api
+type giteaTestServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	repos map[string]map[string]interface{}
+}
+
+func newGiteaTestServer(t *testing.T) *giteaTestServer {
+	s := &giteaTestServer{repos: map[string]map[string]interface{}{}}
+	newRepo := func(owner, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"owner":     map[string]interface{}{"login": owner},
+			"name":      name,
+			"full_name": owner + "/" + name,
+			"html_url":  "https://gitea.example.com/" + owner + "/" + name,
+			"clone_url": "https://gitea.example.com/" + owner + "/" + name + ".git",
+			"ssh_url":   "git@gitea.example.com:" + owner + "/" + name + ".git",
+			"topics":    []string{},
+		}
+	}
+	for i := 0; i < 60; i++ {
+		name := fmt.Sprintf("repo%d", i)
+		s.repos["org/"+name] = newRepo("org", name)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/orgs/org", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": 1, "username": "org"})
+	})
+	mux.HandleFunc("/api/v1/orgs/org/repos", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			var repos []map[string]interface{}
+			for i := (page - 1) * limit; i < page*limit && i < 60; i++ {
+				repos = append(repos, s.repos[fmt.Sprintf("org/repo%d", i)])
+			}
+			_ = json.NewEncoder(w).Encode(repos)
+		case http.MethodPost:
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			name := body["name"].(string)
+			if _, ok := s.repos["org/"+name]; ok {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			repo := newRepo("org", name)
+			repo["description"] = body["description"]
+			s.repos["org/"+name] = repo
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(repo)
+		}
+	})
+	mux.HandleFunc("/api/v1/repos/org/", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		name := r.URL.Path[len("/api/v1/repos/org/"):]
+		isTopics := false
+		if l := len(name) - len("/topics"); l > 0 && name[l:] == "/topics" {
+			name, isTopics = name[:l], true
+		}
+		repo, ok := s.repos["org/"+name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case isTopics && r.Method == http.MethodPut:
+			repo["topics"] = body["topics"]
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case r.Method == http.MethodPatch:
+			for k, v := range body {
+				repo[k] = v
+			}
+		case r.Method == http.MethodDelete:
+			delete(s.repos, "org/"+name)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(repo)
+	})
+	mux.HandleFunc("/api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+func TestNewGiteaAPI(t *testing.T) {
+	if _, err := NewGiteaAPI("", ""); err == nil {
+		t.Fatal("expect err when api url is empty")
+	}
+}
+
+func TestGitea_RepositoriesByOrg(t *testing.T) {
+	ts := newGiteaTestServer(t)
+	defer ts.Close()
+
+	c, _ := NewGiteaAPI(ts.URL+"/api/v1", "token")
+	repos, err := c.RepositoriesByOrg("org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 60 {
+		t.Fatalf("expect 60 repos, got %d", len(repos))
+	}
+	if *repos[0].Owner.Name != "org" || *repos[0].SSHURL != "git@gitea.example.com:org/repo0.git" {
+		j, _ := json.Marshal(repos[0])
+		t.Fatalf("unexpected repo: %s", j)
+	}
+
+	if _, err := c.RepositoriesByOrg("not-exist"); err == nil {
+		t.Fatal("expect not found err")
+	}
+}
+
+func TestGitea_CreateRepository(t *testing.T) {
+	ts := newGiteaTestServer(t)
+	defer ts.Close()
+
+	c, _ := NewGiteaAPI(ts.URL+"/api/v1", "token")
+	repo, err := c.CreateRepository(&Repository{
+		Name:        github.String("new-repo"),
+		Description: github.String("i am description."),
+		Homepage:    github.String("https://www.xiexianbin.cn"),
+		Topics:      []string{"mirror"},
+	}, "org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *repo.Homepage != "https://www.xiexianbin.cn" || len(repo.Topics) != 1 {
+		j, _ := json.Marshal(repo)
+		t.Fatalf("unexpected repo: %s", j)
+	}
+
+	// already exist
+	repo, err = c.CreateRepository(&Repository{Name: github.String("repo1")}, "org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *repo.FullName != "org/repo1" {
+		t.Fatalf("unexpected repo full name %s", *repo.FullName)
+	}
+}
+
+func TestGitea_UpdateRepository(t *testing.T) {
+	ts := newGiteaTestServer(t)
+	defer ts.Close()
+
+	c, _ := NewGiteaAPI(ts.URL+"/api/v1", "token")
+	repo, err := c.UpdateRepository("org", "repo2", &Repository{
+		Description: github.String("updated"),
+		Topics:      []string{"a", "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *repo.Description != "updated" || len(repo.Topics) != 2 {
+		j, _ := json.Marshal(repo)
+		t.Fatalf("unexpected repo: %s", j)
+	}
+}
+
+func TestGitea_DeleteAndArchiveRepository(t *testing.T) {
+	ts := newGiteaTestServer(t)
+	defer ts.Close()
+
+	c, _ := NewGiteaAPI(ts.URL+"/api/v1", "token")
+	if err := c.ArchiveRepository("org", "repo1"); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := c.GetRepository("org", "repo1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Archived == nil || !*repo.Archived {
+		j, _ := json.Marshal(repo)
+		t.Fatalf("expect repo1 is archived: %s", j)
+	}
+
+	if err := c.DeleteRepository("org", "repo1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetRepository("org", "repo1"); err == nil {
+		t.Fatal("expect repo1 is deleted")
+	}
+	if err := c.DeleteRepository("org", "repo1"); err == nil {
+		t.Fatal("expect not found err")
+	}
+}
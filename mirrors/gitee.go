@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"gitee.com/openeuler/go-gitee/gitee"
 	"github.com/antihax/optional"
@@ -36,13 +37,17 @@ type GiteeAPI struct {
 	Context     context.Context
 	accessToken string
 	IsAuthed    bool
+	rateLimit   *rateLimit
 }
 
-// NewGiteeAPI return new Gitee API
-func NewGiteeAPI(accessToken string) (*GiteeAPI, error) {
+// NewGiteeAPI return new Gitee API, baseURL default is https://gitee.com/api
+func NewGiteeAPI(baseURL, accessToken string) (*GiteeAPI, error) {
 	ctx := context.Background()
 	// configuration
 	conf := gitee.NewConfiguration()
+	if baseURL != "" {
+		conf.BasePath = strings.TrimSuffix(baseURL, "/")
+	}
 	isAuthed := false
 	if accessToken != "" {
 		// oauth
@@ -52,11 +57,14 @@ func NewGiteeAPI(accessToken string) (*GiteeAPI, error) {
 		conf.HTTPClient = oauth2.NewClient(ctx, ts)
 		isAuthed = true
 	}
+	// track the rate limit by the response headers
+	limit := newRateLimit("gitee")
+	conf.HTTPClient = newRateLimitClient(conf.HTTPClient, limit)
 
 	// git client
 	client := gitee.NewAPIClient(conf)
 
-	return &GiteeAPI{Client: client, Context: ctx, accessToken: accessToken, IsAuthed: isAuthed}, nil
+	return &GiteeAPI{Client: client, Context: ctx, accessToken: accessToken, IsAuthed: isAuthed, rateLimit: limit}, nil
 }
 
 // IsAPIAuthed return is the API auth, true or false
@@ -64,6 +72,11 @@ func (g *GiteeAPI) IsAPIAuthed() bool {
 	return g.IsAuthed
 }
 
+// Quota return the last known rate limit quota, nil if unknown
+func (g *GiteeAPI) Quota() *APIQuota {
+	return g.rateLimit.Quota()
+}
+
 // Organizations list all Organizations
 func (g *GiteeAPI) Organizations(user string) ([]*Organization, error) {
 	page := 1
@@ -86,7 +99,7 @@ func (g *GiteeAPI) GetOrganization(orgName string) (*Organization, error) {
 	}
 	group, resp, err := g.Client.OrganizationsApi.GetV5OrgsOrg(g.Context, orgName, opt)
 	if err != nil {
-		if resp.StatusCode == http.StatusNotFound {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
 			return nil, ErrNotFound("Organization", orgName)
 		}
 		return nil, err
@@ -213,6 +226,26 @@ func (g *GiteeAPI) UpdateRepository(orgName, repoName string, baseRepo *Reposito
 	return formatGiteeRepo(project), nil
 }
 
+// DeleteRepository delete a repository
+func (g *GiteeAPI) DeleteRepository(orgName, repoName string) error {
+	opt := &gitee.DeleteV5ReposOwnerRepoOpts{
+		AccessToken: optional.NewString(g.accessToken),
+	}
+	resp, err := g.Client.RepositoriesApi.DeleteV5ReposOwnerRepo(g.Context, orgName, repoName, opt)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return err
+	}
+	return nil
+}
+
+// ArchiveRepository gitee API v5 can not archive repository, use make-private instead
+func (g *GiteeAPI) ArchiveRepository(orgName, repoName string) error {
+	return ErrNotSupported("gitee", "archive repository")
+}
+
 // RepositoriesByOrg list repositories for special org
 func (g *GiteeAPI) RepositoriesByOrg(orgName string) ([]*Repository, error) {
 	page := 1
@@ -271,15 +304,22 @@ func formatGiteeRepo(project gitee.Project) *Repository {
 		SSHURL:      &project.SshUrl,
 		Homepage:    &project.Homepage,
 		Fork:        &project.Fork,
-		Topics:      []string{},
+		Language:    &project.Language,
 		Private:     &project.Private,
 		//Archived: optional.NewBool(true),
 	}
+	if pushedAt, err := time.Parse(time.RFC3339, project.PushedAt); err == nil {
+		baseRepo.PushedAt = &pushedAt
+	}
 
+	// the namespace name is the display name, use its path as the org name in API
 	if project.Namespace != nil {
+		orgName := project.Namespace.Path
+		if orgName == "" {
+			orgName = project.Namespace.Name
+		}
 		baseRepo.Organization = &Organization{
-			Name: &project.Namespace.Name,
-			//Description: &repo.Namespace.Description,
+			Name: &orgName,
 			Type: &project.Namespace.Type_,
 		}
 	}
@@ -35,7 +35,7 @@ const (
 
 func TestGitee_Organizations(t *testing.T) {
 	accessToken := os.Getenv(GiteeTokenKey)
-	c, err := NewGiteeAPI(accessToken)
+	c, err := NewGiteeAPI("", accessToken)
 	if err != nil {
 		t.Skipf("init gitee api client err: %s", err.Error())
 		return
@@ -54,7 +54,7 @@ func TestGitee_Organizations(t *testing.T) {
 
 func TestGitee_Repositories(t *testing.T) {
 	accessToken := os.Getenv(GiteeTokenKey)
-	c, err := NewGiteeAPI(accessToken)
+	c, err := NewGiteeAPI("", accessToken)
 	if err != nil {
 		t.Skipf("init gitee api client err: %s", err.Error())
 		return
@@ -73,7 +73,7 @@ func TestGitee_Repositories(t *testing.T) {
 
 func TestGitee_GetRepository(t *testing.T) {
 	accessToken := os.Getenv(GiteeTokenKey)
-	c, err := NewGiteeAPI(accessToken)
+	c, err := NewGiteeAPI("", accessToken)
 	if err != nil {
 		t.Skipf("init gitee api client err: %s", err.Error())
 		return
@@ -98,7 +98,7 @@ func TestGitee_GetRepository(t *testing.T) {
 
 func TestGitee_CreateRepository(t *testing.T) {
 	accessToken := os.Getenv(GiteeTokenKey)
-	c, err := NewGiteeAPI(accessToken)
+	c, err := NewGiteeAPI("", accessToken)
 	if err != nil {
 		t.Skipf("init gitee api client err: %s", err.Error())
 		return
@@ -123,7 +123,7 @@ func TestGitee_CreateRepository(t *testing.T) {
 
 func TestGitee_UpdateRepository(t *testing.T) {
 	accessToken := os.Getenv(GiteeTokenKey)
-	c, err := NewGiteeAPI(accessToken)
+	c, err := NewGiteeAPI("", accessToken)
 	if err != nil {
 		t.Skipf("init gitee api client err: %s", err.Error())
 		return
@@ -147,7 +147,7 @@ func TestGitee_UpdateRepository(t *testing.T) {
 
 func TestGitee_RepositoriesByOrg(t *testing.T) {
 	accessToken := os.Getenv(GiteeTokenKey)
-	c, err := NewGiteeAPI(accessToken)
+	c, err := NewGiteeAPI("", accessToken)
 	if err != nil {
 		t.Skipf("init gitee api client err: %s", err.Error())
 		return
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/google/go-github/github"
 	"github.com/xiexianbin/golib/logger"
@@ -35,23 +36,48 @@ type GithubAPI struct {
 	Context     context.Context
 	accessToken string
 	IsAuthed    bool
+	rateLimit   *rateLimit
 }
 
-// NewGithubAPI return new Github API
-func NewGithubAPI(accessToken string) (*GithubAPI, error) {
+// NewGithubAPI return new Github API, if baseURL is not empty, return a Github Enterprise Server API,
+// baseURL like https://github.example.com/api/v3/, uploadURL default is https://github.example.com/api/uploads/
+func NewGithubAPI(baseURL, uploadURL, accessToken string) (*GithubAPI, error) {
 	ctx := context.Background()
-	client := github.NewClient(nil)
+	var tc *http.Client
 	isAuthed := false
 	if accessToken != "" {
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: accessToken},
 		)
-		tc := oauth2.NewClient(ctx, ts)
-		client = github.NewClient(tc)
+		tc = oauth2.NewClient(ctx, ts)
 		isAuthed = true
 	}
+	// track the primary rate limit by the response headers, and the secondary rate limit by `Retry-After`
+	limit := newRateLimit("github")
+	tc = newRateLimitClient(tc, limit)
+
+	client := github.NewClient(tc)
+	if baseURL != "" {
+		if uploadURL == "" {
+			uploadURL = githubEnterpriseUploadURL(baseURL)
+		}
+		var err error
+		client, err = github.NewEnterpriseClient(baseURL, uploadURL, tc)
+		if err != nil {
+			return nil, fmt.Errorf("init github enterprise client %s err: %s", baseURL, err.Error())
+		}
+	}
+
+	return &GithubAPI{Client: client, Context: ctx, accessToken: accessToken, IsAuthed: isAuthed, rateLimit: limit}, nil
+}
 
-	return &GithubAPI{Client: client, Context: ctx, accessToken: accessToken, IsAuthed: isAuthed}, nil
+// githubEnterpriseUploadURL convert https://github.example.com/api/v3 to https://github.example.com/api/uploads
+func githubEnterpriseUploadURL(baseURL string) string {
+	u := strings.TrimSuffix(baseURL, "/")
+	if strings.HasSuffix(u, "/api/v3") {
+		return strings.TrimSuffix(u, "/v3") + "/uploads/"
+	}
+	return baseURL
 }
 
 // IsAPIAuthed return is the API auth, true or false
@@ -59,6 +85,11 @@ func (g *GithubAPI) IsAPIAuthed() bool {
 	return g.IsAuthed
 }
 
+// Quota return the last known rate limit quota, nil if unknown
+func (g *GithubAPI) Quota() *APIQuota {
+	return g.rateLimit.Quota()
+}
+
 // Organizations list Organizations
 func (g *GithubAPI) Organizations(user string) ([]*Organization, error) {
 	page := 1
@@ -83,7 +114,7 @@ func (g *GithubAPI) GetOrganization(orgName string) (*Organization, error) {
 
 	githubOrg, resp, err := g.Client.Organizations.Get(g.Context, orgName)
 	if err != nil {
-		if resp.StatusCode == http.StatusNotFound {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
 			return nil, ErrNotFound("Organization", orgName)
 		}
 		return nil, err
@@ -162,7 +193,7 @@ func (g *GithubAPI) CreateRepository(baseRepo *Repository, orgName string) (*Rep
 	}
 	githubRepo, resp, err := g.Client.Repositories.Create(g.Context, orgName, repo)
 	if err != nil {
-		if resp.StatusCode == http.StatusUnprocessableEntity {
+		if resp != nil && resp.StatusCode == http.StatusUnprocessableEntity {
 			// 422 Repository creation failed.
 			// [{Resource:Repository Field:name Code:custom Message:name already exists on this account}]
 			if baseRepo, err := g.GetRepository(orgName, *repo.Name); err == nil {
@@ -201,6 +232,30 @@ func (g *GithubAPI) UpdateRepository(orgName, repoName string, baseRepo *Reposit
 	return formatGithubRepo(githubRepo), nil
 }
 
+// DeleteRepository delete a repository
+func (g *GithubAPI) DeleteRepository(orgName, repoName string) error {
+	resp, err := g.Client.Repositories.Delete(g.Context, orgName, repoName)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return err
+	}
+	return nil
+}
+
+// ArchiveRepository archive a repository, it becomes read-only
+func (g *GithubAPI) ArchiveRepository(orgName, repoName string) error {
+	_, resp, err := g.Client.Repositories.Edit(g.Context, orgName, repoName, &github.Repository{Archived: github.Bool(true)})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return err
+	}
+	return nil
+}
+
 // RepositoriesByOrg list repositories for special org
 func (g *GithubAPI) RepositoriesByOrg(orgName string) ([]*Repository, error) {
 	page := 1
@@ -258,9 +313,13 @@ func formatGithubRepo(repo *github.Repository) *Repository {
 		Homepage:    repo.Homepage,
 		Fork:        repo.Fork,
 		Topics:      repo.Topics,
+		Language:    repo.Language,
 		Private:     repo.Private,
 		Archived:    repo.Archived,
 	}
+	if repo.PushedAt != nil {
+		baseRepo.PushedAt = &repo.PushedAt.Time
+	}
 
 	if repo.Organization != nil {
 		baseRepo.Organization = &Organization{
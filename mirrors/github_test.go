@@ -19,6 +19,8 @@ package mirrors
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -34,7 +36,7 @@ const (
 
 func TestGithub_Organizations(t *testing.T) {
 	accessToken := os.Getenv(GithubTokenKey)
-	c, err := NewGithubAPI(accessToken)
+	c, err := NewGithubAPI("", "", accessToken)
 	if err != nil {
 		t.Skipf("init github api client err: %s", err.Error())
 		return
@@ -53,7 +55,7 @@ func TestGithub_Organizations(t *testing.T) {
 
 func TestGithub_GetOrganization(t *testing.T) {
 	accessToken := os.Getenv(GithubTokenKey)
-	c, err := NewGithubAPI(accessToken)
+	c, err := NewGithubAPI("", "", accessToken)
 	if err != nil {
 		t.Skipf("init github api client err: %s", err.Error())
 		return
@@ -78,7 +80,7 @@ func TestGithub_GetOrganization(t *testing.T) {
 
 func TestGithub_Repositories(t *testing.T) {
 	accessToken := os.Getenv(GithubTokenKey)
-	c, err := NewGithubAPI(accessToken)
+	c, err := NewGithubAPI("", "", accessToken)
 	if err != nil {
 		t.Skipf("init github api client err: %s", err.Error())
 		return
@@ -97,7 +99,7 @@ func TestGithub_Repositories(t *testing.T) {
 
 func TestGithub_CreateRepository(t *testing.T) {
 	accessToken := os.Getenv(GithubTokenKey)
-	c, err := NewGithubAPI(accessToken)
+	c, err := NewGithubAPI("", "", accessToken)
 	if err != nil {
 		t.Skipf("init github api client err: %s", err.Error())
 		return
@@ -121,7 +123,7 @@ func TestGithub_CreateRepository(t *testing.T) {
 
 func TestGithub_UpdateRepository(t *testing.T) {
 	accessToken := os.Getenv(GithubTokenKey)
-	c, err := NewGithubAPI(accessToken)
+	c, err := NewGithubAPI("", "", accessToken)
 	if err != nil {
 		t.Skipf("init github api client err: %s", err.Error())
 		return
@@ -142,7 +144,7 @@ func TestGithub_UpdateRepository(t *testing.T) {
 
 func TestGithub_RepositoriesByOrg(t *testing.T) {
 	accessToken := os.Getenv(GithubTokenKey)
-	c, err := NewGithubAPI(accessToken)
+	c, err := NewGithubAPI("", "", accessToken)
 	if err != nil {
 		t.Skipf("init github api client err: %s", err.Error())
 		return
@@ -159,3 +161,54 @@ func TestGithub_RepositoriesByOrg(t *testing.T) {
 		t.Log(string(j))
 	}
 }
+
+func TestNewGithubAPI_Enterprise(t *testing.T) {
+	c, err := NewGithubAPI("https://github.example.com/api/v3", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Client.BaseURL.String() != "https://github.example.com/api/v3/" {
+		t.Fatalf("unexpected base url %s", c.Client.BaseURL)
+	}
+	if c.Client.UploadURL.String() != "https://github.example.com/api/uploads/" {
+		t.Fatalf("unexpected upload url %s", c.Client.UploadURL)
+	}
+}
+
+func TestGithub_DeleteAndArchiveRepository(t *testing.T) {
+	var requests []string
+	archived := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.URL.Path != "/api/v3/repos/org/repo":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		case r.Method == http.MethodPatch:
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			archived, _ = body["archived"].(bool)
+			fmt.Fprint(w, `{"name": "repo", "archived": true}`)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewGithubAPI(ts.URL+"/api/v3/", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ArchiveRepository("org", "repo"); err != nil || !archived {
+		t.Fatalf("expect repo is archived, got %v", err)
+	}
+	if err := c.DeleteRepository("org", "repo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteRepository("org", "not-exist"); err == nil || err.Error() != ErrNotFound("Repository", "org/not-exist").Error() {
+		t.Fatalf("expect not found err, got %v", err)
+	}
+	if len(requests) != 3 || requests[0] != "PATCH /api/v3/repos/org/repo" || requests[1] != "DELETE /api/v3/repos/org/repo" {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xiexianbin/golib/logger"
+)
+
+const (
+	maxGitlabPerPage     = 100
+	defaultGitlabBaseURL = "https://gitlab.com/api/v4"
+)
+
+type gitlabNamespace struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Kind     string `json:"kind"` // user or group
+	FullPath string `json:"full_path"`
+}
+
+type gitlabGroup struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	FullPath    string `json:"full_path"`
+	Description string `json:"description"`
+}
+
+type gitlabProject struct {
+	ID                int              `json:"id"`
+	Name              string           `json:"name"`
+	Path              string           `json:"path"`
+	PathWithNamespace string           `json:"path_with_namespace"`
+	Description       string           `json:"description"`
+	WebURL            string           `json:"web_url"`
+	HTTPURLToRepo     string           `json:"http_url_to_repo"`
+	SSHURLToRepo      string           `json:"ssh_url_to_repo"`
+	Visibility        string           `json:"visibility"`
+	Archived          bool             `json:"archived"`
+	Topics            []string         `json:"topics"`
+	TagList           []string         `json:"tag_list"`
+	ForkedFromProject *gitlabProject   `json:"forked_from_project"`
+	LastActivityAt    *time.Time       `json:"last_activity_at"`
+	Namespace         *gitlabNamespace `json:"namespace"`
+}
+
+type GitLabAPI struct {
+	Client      *restClient
+	Context     context.Context
+	accessToken string
+	IsAuthed    bool
+}
+
+// NewGitLabAPI return new GitLab API, baseURL default is https://gitlab.com/api/v4
+func NewGitLabAPI(baseURL, accessToken string) (*GitLabAPI, error) {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = defaultGitlabBaseURL
+	}
+	client := newRESTClient(ctx, baseURL, accessToken)
+
+	return &GitLabAPI{Client: client, Context: ctx, accessToken: accessToken, IsAuthed: accessToken != ""}, nil
+}
+
+// IsAPIAuthed return is the API auth, true or false
+func (g *GitLabAPI) IsAPIAuthed() bool {
+	return g.IsAuthed
+}
+
+// Organizations list groups, which the authenticated user is a member of
+func (g *GitLabAPI) Organizations(user string) ([]*Organization, error) {
+	page := 1
+	query := url.Values{}
+	query.Set("per_page", strconv.Itoa(maxGitlabPerPage))
+
+	var baseOrgs []*Organization
+	for {
+		query.Set("page", strconv.Itoa(page))
+		var groups []*gitlabGroup
+		if _, err := g.Client.do(http.MethodGet, "/groups", query, nil, &groups); err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			baseOrgs = append(baseOrgs, formatGitlabGroup(group))
+		}
+
+		if len(groups) < maxGitlabPerPage {
+			break
+		}
+
+		page += 1
+	}
+
+	return baseOrgs, nil
+}
+
+func (g *GitLabAPI) getGroup(orgName string) (*gitlabGroup, error) {
+	var group gitlabGroup
+	resp, err := g.Client.do(http.MethodGet, "/groups/"+url.PathEscape(orgName), nil, nil, &group)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return nil, ErrNotFound("Organization", orgName)
+		}
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+// GetOrganization get a group by full path
+func (g *GitLabAPI) GetOrganization(orgName string) (*Organization, error) {
+	if orgName == "" {
+		return nil, fmt.Errorf("group name must not be empty")
+	}
+
+	group, err := g.getGroup(orgName)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf("get gitlab %s group: %v", orgName, group)
+
+	return formatGitlabGroup(group), nil
+}
+
+func (g *GitLabAPI) listProjects(path string, query url.Values) ([]*Repository, error) {
+	page := 1
+	query.Set("per_page", strconv.Itoa(maxGitlabPerPage))
+
+	var baseRepos []*Repository
+	for {
+		query.Set("page", strconv.Itoa(page))
+		var projects []*gitlabProject
+		resp, err := g.Client.do(http.MethodGet, path, query, nil, &projects)
+		if err != nil {
+			if isStatus(resp, http.StatusNotFound) {
+				return nil, ErrNotFound("Namespace", path)
+			}
+			return nil, err
+		}
+		for _, project := range projects {
+			baseRepos = append(baseRepos, formatGitlabProject(project))
+		}
+
+		if len(projects) < maxGitlabPerPage {
+			break
+		}
+
+		page += 1
+	}
+
+	return baseRepos, nil
+}
+
+// Repositories list all projects owned by the authenticated user, if user is special, list the user's projects
+//
+//	https://docs.gitlab.com/ee/api/projects.html#list-all-projects if user is empty
+//	https://docs.gitlab.com/ee/api/projects.html#list-user-projects if user is special
+func (g *GitLabAPI) Repositories(user string) ([]*Repository, error) {
+	if user == "" {
+		query := url.Values{}
+		query.Set("owned", "true")
+		return g.listProjects("/projects", query)
+	}
+
+	return g.listProjects(fmt.Sprintf("/users/%s/projects", url.PathEscape(user)), url.Values{})
+}
+
+// GetRepository fetches a project
+func (g *GitLabAPI) GetRepository(orgName, repoName string) (*Repository, error) {
+	var project gitlabProject
+	id := url.PathEscape(orgName + "/" + repoName)
+	resp, err := g.Client.do(http.MethodGet, "/projects/"+id, nil, nil, &project)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return nil, ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return nil, err
+	}
+
+	return formatGitlabProject(&project), nil
+}
+
+// CreateRepository create a new project, if orgName is a group create it in the group,
+// else in the namespace of the authenticated user. if project is already exist, just return it
+func (g *GitLabAPI) CreateRepository(baseRepo *Repository, orgName string) (*Repository, error) {
+	if baseRepo.Name == nil || *baseRepo.Name == "" {
+		return nil, fmt.Errorf("new repo name must not be empty")
+	}
+
+	body := map[string]interface{}{
+		"name": *baseRepo.Name,
+		"path": *baseRepo.Name,
+	}
+	if group, err := g.getGroup(orgName); err == nil {
+		body["namespace_id"] = group.ID
+	}
+	if baseRepo.Description != nil {
+		body["description"] = *baseRepo.Description
+	}
+	if baseRepo.Topics != nil {
+		body["topics"] = baseRepo.Topics
+	}
+	if baseRepo.Private != nil {
+		body["visibility"] = gitlabVisibility(*baseRepo.Private)
+	}
+
+	var project gitlabProject
+	resp, err := g.Client.do(http.MethodPost, "/projects", nil, body, &project)
+	if err != nil {
+		if isStatus(resp, http.StatusBadRequest) && strings.Contains(err.Error(), "has already been taken") {
+			if baseRepo, err := g.GetRepository(orgName, *baseRepo.Name); err == nil {
+				return baseRepo, nil
+			}
+		}
+		return nil, err
+	}
+
+	return formatGitlabProject(&project), nil
+}
+
+// UpdateRepository updates a project, gitlab do not support homepage
+func (g *GitLabAPI) UpdateRepository(orgName, repoName string, baseRepo *Repository) (*Repository, error) {
+	body := map[string]interface{}{}
+	if baseRepo.Description != nil {
+		body["description"] = *baseRepo.Description
+	}
+	if baseRepo.Topics != nil {
+		body["topics"] = baseRepo.Topics
+	}
+	if baseRepo.Private != nil {
+		body["visibility"] = gitlabVisibility(*baseRepo.Private)
+	}
+
+	var project gitlabProject
+	id := url.PathEscape(orgName + "/" + repoName)
+	resp, err := g.Client.do(http.MethodPut, "/projects/"+id, nil, body, &project)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return nil, ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return nil, err
+	}
+
+	return formatGitlabProject(&project), nil
+}
+
+// DeleteRepository delete a project, it may be delayed by the deletion protection of gitlab
+func (g *GitLabAPI) DeleteRepository(orgName, repoName string) error {
+	id := url.PathEscape(orgName + "/" + repoName)
+	resp, err := g.Client.do(http.MethodDelete, "/projects/"+id, nil, nil, nil)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return err
+	}
+	return nil
+}
+
+// ArchiveRepository archive a project, it becomes read-only
+func (g *GitLabAPI) ArchiveRepository(orgName, repoName string) error {
+	id := url.PathEscape(orgName + "/" + repoName)
+	resp, err := g.Client.do(http.MethodPost, "/projects/"+id+"/archive", nil, nil, nil)
+	if err != nil {
+		if isStatus(resp, http.StatusNotFound) {
+			return ErrNotFound("Repository", orgName+"/"+repoName)
+		}
+		return err
+	}
+	return nil
+}
+
+// RepositoriesByOrg list projects for special group
+func (g *GitLabAPI) RepositoriesByOrg(orgName string) ([]*Repository, error) {
+	repos, err := g.listProjects(fmt.Sprintf("/groups/%s/projects", url.PathEscape(orgName)), url.Values{})
+	if err != nil {
+		return nil, fmt.Errorf("list gitlab group %s projects err: %s", orgName, err.Error())
+	}
+
+	return repos, nil
+}
+
+func gitlabVisibility(private bool) string {
+	if private {
+		return "private"
+	}
+	return "public"
+}
+
+func formatGitlabGroup(group *gitlabGroup) *Organization {
+	groupType := "group"
+	baseOrg := &Organization{
+		Name:        &group.FullPath,
+		Description: &group.Description,
+		Type:        &groupType,
+	}
+
+	return baseOrg
+}
+
+func formatGitlabProject(project *gitlabProject) *Repository {
+	private := project.Visibility != "public"
+	fork := project.ForkedFromProject != nil
+	topics := project.Topics
+	if len(topics) == 0 {
+		// tag_list is deprecated in gitlab 14.0 in favor of topics
+		topics = project.TagList
+	}
+	if topics == nil {
+		topics = []string{}
+	}
+
+	baseRepo := &Repository{
+		Name:        &project.Path,
+		FullName:    &project.PathWithNamespace,
+		Description: &project.Description,
+		HTMLURL:     &project.WebURL,
+		CloneURL:    &project.HTTPURLToRepo,
+		GitURL:      &project.SSHURLToRepo,
+		SSHURL:      &project.SSHURLToRepo,
+		Fork:        &fork,
+		Topics:      topics,
+		Private:     &private,
+		Archived:    &project.Archived,
+		PushedAt:    project.LastActivityAt,
+	}
+
+	if project.Namespace != nil {
+		baseRepo.Owner = &User{
+			Name: &project.Namespace.FullPath,
+			Type: &project.Namespace.Kind,
+		}
+		if project.Namespace.Kind == "group" {
+			baseRepo.Organization = &Organization{
+				Name: &project.Namespace.FullPath,
+				Type: &project.Namespace.Kind,
+			}
+		}
+	}
+
+	return baseRepo
+}
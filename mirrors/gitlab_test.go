@@ -0,0 +1,131 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func newGitlabTestServer(t *testing.T) *httptest.Server {
+	project := func(i int) map[string]interface{} {
+		name := fmt.Sprintf("repo%d", i)
+		return map[string]interface{}{
+			"id":                  i,
+			"name":                name,
+			"path":                name,
+			"path_with_namespace": "group/" + name,
+			"web_url":             "https://gitlab.example.com/group/" + name,
+			"http_url_to_repo":    "https://gitlab.example.com/group/" + name + ".git",
+			"ssh_url_to_repo":     "git@gitlab.example.com:group/" + name + ".git",
+			"visibility":          "private",
+			"topics":              []string{"mirror"},
+			"namespace":           map[string]interface{}{"id": 7, "kind": "group", "full_path": "group"},
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/groups/group/projects", func(w http.ResponseWriter, r *http.Request) {
+		// 150 projects, split in two pages
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		var projects []map[string]interface{}
+		begin, end := 0, maxGitlabPerPage
+		if page == 2 {
+			begin, end = maxGitlabPerPage, 150
+		}
+		for i := begin; i < end; i++ {
+			projects = append(projects, project(i))
+		}
+		_ = json.NewEncoder(w).Encode(projects)
+	})
+	mux.HandleFunc("/api/v4/groups/group", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": 7, "full_path": "group"})
+	})
+	mux.HandleFunc("/api/v4/groups/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/api/v4/projects", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["namespace_id"] != float64(7) || body["visibility"] != "private" {
+			t.Errorf("unexpected create project body: %v", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(project(200))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestGitLab_RepositoriesByOrg(t *testing.T) {
+	ts := newGitlabTestServer(t)
+	defer ts.Close()
+
+	c, err := NewGitLabAPI(ts.URL+"/api/v4", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := c.RepositoriesByOrg("group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 150 {
+		t.Fatalf("expect 150 repos, got %d", len(repos))
+	}
+	repo := repos[0]
+	if *repo.Name != "repo0" || *repo.SSHURL != "git@gitlab.example.com:group/repo0.git" ||
+		*repo.CloneURL != "https://gitlab.example.com/group/repo0.git" || !*repo.Private ||
+		*repo.Organization.Name != "group" {
+		j, _ := json.Marshal(repo)
+		t.Fatalf("unexpected repo: %s", j)
+	}
+}
+
+func TestGitLab_GetOrganization(t *testing.T) {
+	ts := newGitlabTestServer(t)
+	defer ts.Close()
+
+	c, _ := NewGitLabAPI(ts.URL+"/api/v4", "")
+	if _, err := c.GetOrganization("group"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetOrganization("not-exist"); err == nil {
+		t.Fatal("expect not found err")
+	}
+}
+
+func TestGitLab_CreateRepository(t *testing.T) {
+	ts := newGitlabTestServer(t)
+	defer ts.Close()
+
+	c, _ := NewGitLabAPI(ts.URL+"/api/v4", "token")
+	repo, err := c.CreateRepository(&Repository{
+		Name:    github.String("repo200"),
+		Private: github.Bool(true),
+	}, "group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *repo.FullName != "group/repo200" {
+		t.Fatalf("unexpected repo full name %s", *repo.FullName)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimitedAPI is shared by the mirror workers, it keeps at least interval between the starts of two calls
+// of IGitAPI, the calls run concurrently, only the wait for the interval is serialized.
+// the transient failures are retried by retry, every attempt waits for the interval
+type rateLimitedAPI struct {
+	IGitAPI
+
+	mu       sync.Mutex
+	interval time.Duration
+	last     time.Time // the start of the last reserved call
+	retry    *RetryPolicy
+}
+
+// newRateLimitedAPI wrap api, rate is the max requests per second, 0 means no interval, nil retry is no retry
+func newRateLimitedAPI(api IGitAPI, rate float64, retry *RetryPolicy) *rateLimitedAPI {
+	var interval time.Duration
+	if rate > 0 {
+		interval = time.Duration(float64(time.Second) / rate)
+	}
+
+	return &rateLimitedAPI{IGitAPI: api, interval: interval, retry: retry}
+}
+
+// acquire reserve the start of next call, at least interval after the last one, and sleep until it.
+// the lock is only held to reserve, so the waiting and running calls do not block each other
+func (r *rateLimitedAPI) acquire() {
+	if r.interval <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	next := r.last.Add(r.interval)
+	if now := time.Now(); next.Before(now) {
+		next = now
+	}
+	r.last = next
+	r.mu.Unlock()
+
+	time.Sleep(time.Until(next))
+}
+
+// call run fn in the interval, and retry it by the retry policy, name is the operation shown in log
+func (r *rateLimitedAPI) call(name string, fn func() error) error {
+	return r.retry.Do(name, func() error {
+		r.acquire()
+		return fn()
+	})
+}
+
+func (r *rateLimitedAPI) Organizations(user string) (orgs []*Organization, err error) {
+	err = r.call("list organizations of "+user, func() (err error) {
+		orgs, err = r.IGitAPI.Organizations(user)
+		return err
+	})
+	return orgs, err
+}
+
+func (r *rateLimitedAPI) GetOrganization(orgName string) (org *Organization, err error) {
+	err = r.call("get organization "+orgName, func() (err error) {
+		org, err = r.IGitAPI.GetOrganization(orgName)
+		return err
+	})
+	return org, err
+}
+
+func (r *rateLimitedAPI) Repositories(user string) (repos []*Repository, err error) {
+	err = r.call("list repos of user "+user, func() (err error) {
+		repos, err = r.IGitAPI.Repositories(user)
+		return err
+	})
+	return repos, err
+}
+
+func (r *rateLimitedAPI) GetRepository(orgName, repoName string) (repo *Repository, err error) {
+	err = r.call("get repo "+orgName+"/"+repoName, func() (err error) {
+		repo, err = r.IGitAPI.GetRepository(orgName, repoName)
+		return err
+	})
+	return repo, err
+}
+
+func (r *rateLimitedAPI) CreateRepository(baseRepo *Repository, orgName string) (repo *Repository, err error) {
+	err = r.call("create repo "+orgName+"/"+*baseRepo.Name, func() (err error) {
+		repo, err = r.IGitAPI.CreateRepository(baseRepo, orgName)
+		return err
+	})
+	return repo, err
+}
+
+func (r *rateLimitedAPI) UpdateRepository(orgName, repoName string, baseRepo *Repository) (repo *Repository, err error) {
+	err = r.call("update repo "+orgName+"/"+repoName, func() (err error) {
+		repo, err = r.IGitAPI.UpdateRepository(orgName, repoName, baseRepo)
+		return err
+	})
+	return repo, err
+}
+
+func (r *rateLimitedAPI) RepositoriesByOrg(orgName string) (repos []*Repository, err error) {
+	err = r.call("list repos of org "+orgName, func() (err error) {
+		repos, err = r.IGitAPI.RepositoriesByOrg(orgName)
+		return err
+	})
+	return repos, err
+}
+
+func (r *rateLimitedAPI) DeleteRepository(orgName, repoName string) error {
+	return r.call("delete repo "+orgName+"/"+repoName, func() error {
+		return r.IGitAPI.DeleteRepository(orgName, repoName)
+	})
+}
+
+func (r *rateLimitedAPI) ArchiveRepository(orgName, repoName string) error {
+	return r.call("archive repo "+orgName+"/"+repoName, func() error {
+		return r.IGitAPI.ArchiveRepository(orgName, repoName)
+	})
+}
+
+// Quota return the rate limit quota of api, nil if api does not track it
+func (r *rateLimitedAPI) Quota() *APIQuota {
+	if api, ok := r.IGitAPI.(quotaAPI); ok {
+		return api.Quota()
+	}
+	return nil
+}
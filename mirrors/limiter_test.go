@@ -0,0 +1,74 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedAPI(t *testing.T) {
+	api, _ := NewFileAPI()
+	r := newRateLimitedAPI(api, 20, nil)
+	dir := t.TempDir()
+
+	begin := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = r.RepositoriesByOrg(dir)
+		}()
+	}
+	wg.Wait()
+
+	// the first call is not delayed, and 3 intervals of 50ms
+	if cost := time.Since(begin); cost < 150*time.Millisecond {
+		t.Fatalf("expect at least 150ms, cost %s", cost)
+	}
+}
+
+// slowAPI is an IGitAPI whose RepositoriesByOrg takes delay, like a paginated listing
+type slowAPI struct {
+	IGitAPI
+	delay time.Duration
+}
+
+func (s *slowAPI) RepositoriesByOrg(orgName string) ([]*Repository, error) {
+	time.Sleep(s.delay)
+	return nil, nil
+}
+
+func TestRateLimitedAPI_Concurrent(t *testing.T) {
+	r := newRateLimitedAPI(&slowAPI{delay: 200 * time.Millisecond}, 20, nil)
+
+	begin := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = r.RepositoriesByOrg("org")
+		}()
+	}
+	wg.Wait()
+
+	// the calls start every 50ms and run concurrently, not 4 * 200ms one by one
+	if cost := time.Since(begin); cost < 350*time.Millisecond || cost > 700*time.Millisecond {
+		t.Fatalf("expect about 350ms, cost %s", cost)
+	}
+}
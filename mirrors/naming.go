@@ -0,0 +1,107 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// the kinds of destination name rule
+const (
+	nameRuleLower    = "lower"    // lower-case the name
+	nameRuleReplace  = "replace:" // replace literal string, like `replace:.=>-`
+	nameRuleRegex    = "re:"      // replace regex, like `re:^team-(.*)$=>$1`
+	nameRuleTemplate = "{name}"   // template, like `gh-{name}`
+)
+
+// nameRule transform the repo name to destination name
+type nameRule struct {
+	raw      string
+	lower    bool
+	re       *regexp.Regexp
+	old, new string
+	template string
+}
+
+func newNameRule(raw string) (*nameRule, error) {
+	switch {
+	case raw == nameRuleLower:
+		return &nameRule{raw: raw, lower: true}, nil
+	case strings.HasPrefix(raw, nameRuleReplace):
+		old, new, ok := strings.Cut(strings.TrimPrefix(raw, nameRuleReplace), "=>")
+		if !ok || old == "" {
+			return nil, fmt.Errorf("invalid name rule %s, like 'replace:.=>-'", raw)
+		}
+		return &nameRule{raw: raw, old: old, new: new}, nil
+	case strings.HasPrefix(raw, nameRuleRegex):
+		expr, new, ok := strings.Cut(strings.TrimPrefix(raw, nameRuleRegex), "=>")
+		if !ok {
+			return nil, fmt.Errorf("invalid name rule %s, like 're:^team-(.*)$=>$1'", raw)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name rule %s: %s", raw, err.Error())
+		}
+		return &nameRule{raw: raw, re: re, new: new}, nil
+	case strings.Contains(raw, nameRuleTemplate):
+		return &nameRule{raw: raw, template: raw}, nil
+	default:
+		return nil, fmt.Errorf("un-support name rule %s", raw)
+	}
+}
+
+func (r *nameRule) apply(name string) string {
+	switch {
+	case r.lower:
+		return strings.ToLower(name)
+	case r.re != nil:
+		return r.re.ReplaceAllString(name, r.new)
+	case r.template != "":
+		return strings.ReplaceAll(r.template, nameRuleTemplate, name)
+	default:
+		return strings.ReplaceAll(name, r.old, r.new)
+	}
+}
+
+// NameRules is the ordered rules of destination name, every rule transforms the result of previous rule
+type NameRules []*nameRule
+
+// NewNameRules compile the rules, the empty rules are ignored
+func NewNameRules(rules []string) (NameRules, error) {
+	var nameRules NameRules
+	for _, raw := range rules {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		rule, err := newNameRule(raw)
+		if err != nil {
+			return nil, err
+		}
+		nameRules = append(nameRules, rule)
+	}
+
+	return nameRules, nil
+}
+
+// Apply transform name by the rules in order
+func (nr NameRules) Apply(name string) string {
+	for _, rule := range nr {
+		name = rule.apply(name)
+	}
+
+	return name
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestNameRules_Apply(t *testing.T) {
+	rules, err := NewNameRules([]string{"re:^team-(.*)$=>$1", "lower", "replace:.=>-", "gh-{name}", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"team-Web.App", "gh-web-app"},
+		{"repo", "gh-repo"},
+		{"my-team-x", "gh-my-team-x"},
+	}
+	for _, tt := range tests {
+		if got := rules.Apply(tt.name); got != tt.want {
+			t.Errorf("Apply(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	for _, raw := range []string{"upper", "replace:=>-", "re:(=>x", "re:abc"} {
+		if _, err := NewNameRules([]string{raw}); err == nil {
+			t.Errorf("expect invalid name rule %s err", raw)
+		}
+	}
+}
+
+func TestMirror_jobs_Collision(t *testing.T) {
+	var srcRepos []*Repository
+	for _, name := range []string{"Web", "web", "api", "team-api", "tool"} {
+		srcRepos = append(srcRepos, &Repository{Name: github.String(name)})
+	}
+	m := &Mirror{srcRepos: srcRepos, srcReposMap: ReposToMap(srcRepos), Mappings: map[string]string{"tool": "tool"}}
+	m.nameRules, _ = NewNameRules([]string{"re:^team-=>", "lower"})
+
+	jobs, results := m.jobs()
+	if len(jobs) != 1 || *jobs[0].srcRepo.Name != "tool" {
+		t.Fatalf("expect only tool is mirrored, got %d jobs", len(jobs))
+	}
+	for _, result := range results[:4] {
+		if result.Status != RepoFailed || result.Err == nil {
+			t.Errorf("expect %s is failed by collision, got %+v", result.SrcRepo, result)
+		}
+	}
+	if results[0].Err.Error() != "destination name web collides, mapped from Web, web" {
+		t.Errorf("unexpected err %s", results[0].Err)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/x-actions/git-mirrors/constants"
+	"github.com/xiexianbin/golib/logger"
+)
+
+// OrphanResult is the outcome of the orphan policy on one dst repo, which is not mirrored from any source repo
+type OrphanResult struct {
+	DstRepo     string `json:"dst_repo"`
+	DstFullName string `json:"dst_full_name"`
+	Action      string `json:"action"` // the orphan policy applied, or planned by dry-run
+	Err         error  `json:"-"`
+}
+
+// validateOrphanPolicy check the orphan policy, delete must be confirmed by ConfirmDelete, except dry-run
+func (m *Mirror) validateOrphanPolicy() error {
+	switch m.OrphanPolicy {
+	case "", constants.OrphanKeep, constants.OrphanArchive, constants.OrphanMakePrivate:
+	case constants.OrphanDelete:
+		if !m.ConfirmDelete && !m.DryRun {
+			return fmt.Errorf("orphan-policy %s can not be undone, confirm it by confirm-delete", m.OrphanPolicy)
+		}
+	default:
+		return fmt.Errorf("un-support orphan-policy %s", m.OrphanPolicy)
+	}
+	return nil
+}
+
+// repoOwner return the organization of repo, or its owner for the user repo, empty if both unknown
+func repoOwner(repo *Repository) string {
+	if repo.Organization != nil && repo.Organization.Name != nil {
+		return *repo.Organization.Name
+	}
+	if repo.Owner != nil && repo.Owner.Name != nil {
+		return *repo.Owner.Name
+	}
+	return ""
+}
+
+// isOwner return whether owner is org, the names are compared in lower-case, and the personal project
+// key `~user` of bitbucket data center is the user
+func isOwner(owner, org string) bool {
+	return strings.EqualFold(strings.TrimPrefix(owner, "~"), strings.TrimPrefix(org, "~"))
+}
+
+// orphans return the dst repos of DstOrg which are not the destination of any src repo, the names are
+// compared in lower-case. the authed user repos of some git services include the repos of other orgs and
+// collaborators, they are never orphans. the dst repos match black-list are kept, and the private dst repos
+// are kept when the src API is not authed, because the private src repos are not listed
+func (m *Mirror) orphans() []*Repository {
+	mapped := make(map[string]bool, len(m.srcRepos))
+	for _, srcRepo := range m.srcRepos {
+		mapped[strings.ToLower(m.getDstRepoName(*srcRepo.Name))] = true
+	}
+	srcAuthed := true
+	if client, ok := m.srcAPI.(IGitAPI); ok {
+		srcAuthed = client.IsAPIAuthed()
+	}
+
+	var orphans []*Repository
+	for _, dstRepo := range m.dstRepos {
+		name := *dstRepo.Name
+		if owner := repoOwner(dstRepo); !isOwner(owner, m.DstOrg) {
+			logger.Debugf("repo %s/%s is not owned by %s, it is not an orphan.", owner, name, m.DstOrg)
+			continue
+		}
+		if mapped[strings.ToLower(name)] {
+			continue
+		}
+		if rule, ok := m.blackList.Match(name); ok {
+			logger.Debugf("orphan repo %s matches black-list rule %s, keep it.", m.dstFullName(name), rule)
+			continue
+		}
+		if !srcAuthed && dstRepo.Private != nil && *dstRepo.Private {
+			logger.Warnf("orphan repo %s is private, its source may be private and not listed without auth, keep it.",
+				m.dstFullName(name))
+			continue
+		}
+		orphans = append(orphans, dstRepo)
+	}
+
+	return orphans
+}
+
+// handleOrphans apply OrphanPolicy to the orphan dst repos, the repos already archived or private are
+// skipped by archive and make-private. dry-run only plans the actions
+func (m *Mirror) handleOrphans() []*OrphanResult {
+	if m.OrphanPolicy == "" || m.OrphanPolicy == constants.OrphanKeep {
+		return nil
+	}
+	client, ok := m.dstAPI.(IGitAPI)
+	if !ok {
+		logger.Warnf("the repos of destination %s can not be listed, skip orphan-policy %s.", m.DstGit, m.OrphanPolicy)
+		return nil
+	}
+	// all the dst repos are orphans if source lists nothing, it is more likely a mistake
+	if len(m.srcRepos) == 0 {
+		logger.Warnf("no repo in source %s/%s, skip orphan-policy %s.", m.SrcGit, m.SrcOrg, m.OrphanPolicy)
+		return nil
+	}
+
+	var results []*OrphanResult
+	for _, dstRepo := range m.orphans() {
+		name := *dstRepo.Name
+		if m.OrphanPolicy == constants.OrphanArchive && dstRepo.Archived != nil && *dstRepo.Archived ||
+			m.OrphanPolicy == constants.OrphanMakePrivate && dstRepo.Private != nil && *dstRepo.Private {
+			logger.Debugf("orphan repo %s is already archived or private, skip %s.", m.dstFullName(name), m.OrphanPolicy)
+			continue
+		}
+
+		orphan := &OrphanResult{DstRepo: name, DstFullName: m.dstFullName(name), Action: m.OrphanPolicy}
+		results = append(results, orphan)
+		if m.DryRun {
+			continue
+		}
+
+		orgName := repoOwner(dstRepo)
+		logger.Infof("orphan repo %s/%s/%s is not in source, %s it", m.DstGit, orgName, name, m.OrphanPolicy)
+		switch m.OrphanPolicy {
+		case constants.OrphanArchive:
+			orphan.Err = client.ArchiveRepository(orgName, name)
+		case constants.OrphanMakePrivate:
+			repo := *dstRepo
+			private := true
+			repo.Private = &private
+			_, orphan.Err = client.UpdateRepository(orgName, name, &repo)
+		case constants.OrphanDelete:
+			orphan.Err = client.DeleteRepository(orgName, name)
+		}
+		if orphan.Err != nil {
+			logger.Errorf("%s orphan repo %s/%s err: %s", m.OrphanPolicy, orgName, name, orphan.Err.Error())
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,179 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitee.com/openeuler/go-gitee/gitee"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+func TestMirror_Do_Orphans(t *testing.T) {
+	tmp := t.TempDir()
+	m := newTestMirror(t, tmp, "repo1", "repo2", "repo3", "repo4")
+	if _, err := m.Do(); err != nil {
+		t.Fatal(err)
+	}
+
+	// repo2, repo3 and repo4 disappear from source, repo4 is protected by black-list
+	m.SrcRepos = []string{"repo1"}
+	m.BlackList = []string{"repo4"}
+	m.StateStore = constants.StateStoreNone
+	api, _ := NewFileAPI()
+	dstOrg := filepath.Join(tmp, "backup")
+
+	m.OrphanPolicy = constants.OrphanKeep
+	result, err := m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Orphans) != 0 {
+		t.Errorf("expect orphans are kept, got %v", result.Orphans)
+	}
+
+	m.OrphanPolicy = constants.OrphanArchive
+	if result, err = m.Do(); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Orphans) != 2 || result.Orphans[0].Err != nil || result.Orphans[1].Err != nil {
+		t.Fatalf("expect 2 orphans are archived, got %+v", result.Orphans)
+	}
+	repo, err := api.GetRepository(dstOrg, "repo2")
+	if err != nil || repo.Archived == nil || !*repo.Archived {
+		t.Errorf("expect repo2 is archived, got %+v, %v", repo, err)
+	}
+
+	// the archived repos are not changed again, make-private changes them
+	if result, err = m.Do(); err != nil || len(result.Orphans) != 0 {
+		t.Errorf("expect archived orphans are skipped, got %+v, %v", result.Orphans, err)
+	}
+	m.OrphanPolicy = constants.OrphanMakePrivate
+	if result, err = m.Do(); err != nil || len(result.Orphans) != 2 {
+		t.Fatalf("expect 2 orphans are private, got %+v, %v", result.Orphans, err)
+	}
+	if repo, err = api.GetRepository(dstOrg, "repo3"); err != nil || repo.Private == nil || !*repo.Private {
+		t.Errorf("expect repo3 is private, got %+v, %v", repo, err)
+	}
+
+	// delete must be confirmed, except dry-run
+	m.OrphanPolicy = constants.OrphanDelete
+	if _, err = m.Do(); err == nil {
+		t.Fatal("expect err of un-confirmed delete")
+	}
+	m.DryRun = true
+	if result, err = m.Do(); err != nil || len(result.Orphans) != 2 {
+		t.Fatalf("expect 2 orphans are planned to delete, got %+v, %v", result.Orphans, err)
+	}
+	if _, err := os.Stat(filepath.Join(dstOrg, "repo2.git")); err != nil {
+		t.Errorf("expect repo2 is not deleted by dry-run, got %v", err)
+	}
+
+	m.DryRun = false
+	m.ConfirmDelete = true
+	if result, err = m.Do(); err != nil || len(result.Orphans) != 2 {
+		t.Fatalf("expect 2 orphans are deleted, got %+v, %v", result.Orphans, err)
+	}
+	for name, exist := range map[string]bool{"repo1": true, "repo2": false, "repo3": false, "repo4": true} {
+		if _, err := api.GetRepository(dstOrg, name); (err == nil) != exist {
+			t.Errorf("expect %s exist %v, got %v", name, exist, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dstOrg, "repo2.json")); !os.IsNotExist(err) {
+		t.Errorf("expect metadata of repo2 is deleted, got %v", err)
+	}
+}
+
+// recordAPI record the deleted repos
+type recordAPI struct {
+	IGitAPI
+	changed []string
+}
+
+func (r *recordAPI) DeleteRepository(orgName, repoName string) error {
+	r.changed = append(r.changed, orgName+"/"+repoName)
+	return nil
+}
+
+func TestMirror_HandleOrphans_Owner(t *testing.T) {
+	newRepo := func(owner, org, name string) *Repository {
+		repo := &Repository{Name: &name, Owner: &User{Name: &owner}}
+		if org != "" {
+			repo.Organization = &Organization{Name: &org}
+		}
+		return repo
+	}
+	api := &recordAPI{}
+	m := &Mirror{SrcGit: constants.GITHUB, SrcOrg: "src", DstGit: constants.GITEE, DstOrg: "me",
+		OrphanPolicy: constants.OrphanDelete, ConfirmDelete: true, dstAPI: api}
+	m.srcRepos = []*Repository{newRepo("src", "", "repo1")}
+	// the authed user repos include the repos of other orgs and collaborators
+	m.dstRepos = []*Repository{
+		newRepo("me", "", "repo1"),
+		newRepo("Me", "", "gone"),
+		newRepo("me", "other-org", "org-repo"),
+		newRepo("friend", "", "shared"),
+	}
+
+	orphans := m.handleOrphans()
+	if len(orphans) != 1 || orphans[0].DstRepo != "gone" {
+		t.Errorf("expect only gone is orphan, got %+v", orphans)
+	}
+	if len(api.changed) != 1 || api.changed[0] != "Me/gone" {
+		t.Errorf("expect only Me/gone is deleted, got %v", api.changed)
+	}
+}
+
+func TestMirror_HandleOrphans_OwnerPath(t *testing.T) {
+	giteeRepo := func(name string) *Repository {
+		return formatGiteeRepo(gitee.Project{
+			Name:      name,
+			Owner:     &gitee.UserBasic{Login: "alice", Name: "Alice Zhang"},
+			Namespace: &gitee.Namespace{Name: "Alice Zhang", Path: "alice", Type_: "personal"},
+		})
+	}
+	bitbucketServerRepo := func(name string) *Repository {
+		return formatBitbucketServerRepo(&bitbucketServerRepository{
+			Slug:    name,
+			Project: &bitbucketServerProject{Key: "~ALICE", Name: "Alice Zhang", Type: "PERSONAL"},
+		})
+	}
+	cases := []struct {
+		dstGit   string
+		newRepo  func(name string) *Repository
+		expected string
+	}{
+		{constants.GITEE, giteeRepo, "alice/gone"},
+		{constants.BITBUCKETSERVER, bitbucketServerRepo, "~ALICE/gone"},
+	}
+	for _, c := range cases {
+		api := &recordAPI{}
+		m := &Mirror{SrcGit: constants.GITHUB, SrcOrg: "src", DstGit: c.dstGit, DstOrg: "alice",
+			OrphanPolicy: constants.OrphanDelete, ConfirmDelete: true, dstAPI: api}
+		name := "repo1"
+		m.srcRepos = []*Repository{{Name: &name}}
+		m.dstRepos = []*Repository{c.newRepo("repo1"), c.newRepo("gone")}
+
+		if orphans := m.handleOrphans(); len(orphans) != 1 || orphans[0].DstRepo != "gone" {
+			t.Errorf("expect only gone is orphan of %s, got %+v", c.dstGit, orphans)
+		}
+		if len(api.changed) != 1 || api.changed[0] != c.expected {
+			t.Errorf("expect only %s is deleted, got %v", c.expected, api.changed)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// regexPatternPrefix is the prefix of regex pattern, like `re:^svc-.*`
+const regexPatternPrefix = "re:"
+
+// namePattern match repo name by exact name, glob like `*-archive`, or regex with prefix `re:`
+type namePattern struct {
+	raw  string
+	glob bool
+	re   *regexp.Regexp
+}
+
+func newNamePattern(raw string) (*namePattern, error) {
+	if strings.HasPrefix(raw, regexPatternPrefix) {
+		re, err := regexp.Compile(strings.TrimPrefix(raw, regexPatternPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex pattern %s: %s", raw, err.Error())
+		}
+		return &namePattern{raw: raw, re: re}, nil
+	}
+
+	if strings.ContainsAny(raw, "*?[") {
+		if _, err := path.Match(raw, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %s: %s", raw, err.Error())
+		}
+		return &namePattern{raw: raw, glob: true}, nil
+	}
+
+	return &namePattern{raw: raw}, nil
+}
+
+// isExact return true if the pattern is a repo name
+func (p *namePattern) isExact() bool {
+	return !p.glob && p.re == nil
+}
+
+func (p *namePattern) match(name string) bool {
+	switch {
+	case p.re != nil:
+		return p.re.MatchString(name)
+	case p.glob:
+		ok, _ := path.Match(p.raw, name)
+		return ok
+	default:
+		return p.raw == name
+	}
+}
+
+// NameMatcher is the black or white list of repo names
+type NameMatcher []*namePattern
+
+// NewNameMatcher compile the patterns, the empty patterns are ignored
+func NewNameMatcher(patterns []string) (NameMatcher, error) {
+	var matcher NameMatcher
+	for _, raw := range patterns {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		p, err := newNamePattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		matcher = append(matcher, p)
+	}
+
+	return matcher, nil
+}
+
+// Match return the first pattern which matches name
+func (nm NameMatcher) Match(name string) (string, bool) {
+	for _, p := range nm {
+		if p.match(name) {
+			return p.raw, true
+		}
+	}
+
+	return "", false
+}
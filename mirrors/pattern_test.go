@@ -0,0 +1,81 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestNameMatcher_Match(t *testing.T) {
+	matcher, err := NewNameMatcher([]string{"repo1", " *-archive", "re:^svc-.*", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matcher) != 3 {
+		t.Fatalf("expect 3 patterns, got %d", len(matcher))
+	}
+
+	tests := []struct {
+		name     string
+		wantRule string
+		wantOK   bool
+	}{
+		{"repo1", "repo1", true},
+		{"repo11", "", false},
+		{"old-archive", "*-archive", true},
+		{"svc-api", "re:^svc-.*", true},
+		{"my-svc-api", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := matcher.Match(tt.name)
+			if rule != tt.wantRule || ok != tt.wantOK {
+				t.Errorf("Match(%s) = %s, %v, want %s, %v", tt.name, rule, ok, tt.wantRule, tt.wantOK)
+			}
+		})
+	}
+
+	for _, p := range []string{"re:(", "[a-"} {
+		if _, err := NewNameMatcher([]string{p}); err == nil {
+			t.Errorf("expect invalid pattern %s err", p)
+		}
+	}
+}
+
+func TestMirror_jobs(t *testing.T) {
+	var srcRepos []*Repository
+	for _, name := range []string{"svc-a", "svc-b-archive", "web", "tool"} {
+		srcRepos = append(srcRepos, &Repository{Name: github.String(name)})
+	}
+	m := &Mirror{srcRepos: srcRepos, srcReposMap: ReposToMap(srcRepos)}
+	m.blackList, _ = NewNameMatcher([]string{"*-archive"})
+	m.whiteList, _ = NewNameMatcher([]string{"missing", "re:^svc-", "svc-a", "tool"})
+
+	jobs, results := m.jobs()
+	if len(results) != 4 || len(jobs) != 2 {
+		t.Fatalf("expect 4 results and 2 jobs, got %d and %d", len(results), len(jobs))
+	}
+	if results[0].SrcRepo != "missing" || results[0].Reason != "not in source" {
+		t.Errorf("unexpected %+v", results[0])
+	}
+	if results[2].SrcRepo != "svc-b-archive" || results[2].Reason != "in black-list rule *-archive" {
+		t.Errorf("expect black-list has higher priority, got %+v", results[2])
+	}
+	if *jobs[0].srcRepo.Name != "svc-a" || *jobs[1].srcRepo.Name != "tool" || jobs[1].index != 3 {
+		t.Errorf("unexpected jobs %s(%d), %s(%d)", *jobs[0].srcRepo.Name, jobs[0].index, *jobs[1].srcRepo.Name, jobs[1].index)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PlainGit is a git endpoint without forge API, like gitolite, a plain ssh host or a directory.
+// The repo urls are rendered from URLTemplate, eg: `ssh://git@host/{org}/{name}.git`
+type PlainGit struct {
+	URLTemplate string
+	Org         string
+	Repos       []string
+}
+
+// NewPlainGit return new PlainGit, repos is the static repo list, only used when it is the source
+func NewPlainGit(urlTemplate, org string, repos []string) (*PlainGit, error) {
+	if !strings.Contains(urlTemplate, "{name}") {
+		return nil, fmt.Errorf("git url template %s must contain {name}", urlTemplate)
+	}
+
+	return &PlainGit{URLTemplate: urlTemplate, Org: org, Repos: RemoveDuplicates(repos)}, nil
+}
+
+// URL render the url of repo name
+func (p *PlainGit) URL(name string) string {
+	return strings.NewReplacer("{org}", p.Org, "{name}", name).Replace(p.URLTemplate)
+}
+
+// Repository return the Repository of name, all the clone urls are the rendered url
+func (p *PlainGit) Repository(name string) *Repository {
+	url := p.URL(name)
+	fullName := p.Org + "/" + name
+	return &Repository{
+		Owner: &User{
+			Name: &p.Org,
+		},
+		Name:     &name,
+		FullName: &fullName,
+		CloneURL: &url,
+		GitURL:   &url,
+		SSHURL:   &url,
+	}
+}
+
+// Repositories return the Repository list of Repos
+func (p *PlainGit) Repositories() []*Repository {
+	repos := make([]*Repository, 0, len(p.Repos))
+	for _, name := range p.Repos {
+		if name = strings.TrimSpace(name); name != "" {
+			repos = append(repos, p.Repository(name))
+		}
+	}
+
+	return repos
+}
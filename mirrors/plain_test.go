@@ -0,0 +1,43 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"testing"
+)
+
+func TestNewPlainGit(t *testing.T) {
+	if _, err := NewPlainGit("ssh://git@host/{org}/repo.git", "org", nil); err == nil {
+		t.Fatal("expect err when url template without {name}")
+	}
+}
+
+func TestPlainGit_Repositories(t *testing.T) {
+	p, err := NewPlainGit("ssh://git@host/{org}/{name}.git", "org", []string{"a", " b", "", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos := p.Repositories()
+	if len(repos) != 2 {
+		t.Fatalf("expect 2 repos, got %d", len(repos))
+	}
+	for _, repo := range repos {
+		url := "ssh://git@host/org/" + *repo.Name + ".git"
+		if GitURL(repo, GitKeyAuth) != url || GitURL(repo, GitAccessTokenAuth) != url {
+			t.Fatalf("unexpected url %s of repo %s", GitURL(repo, GitKeyAuth), *repo.Name)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/xiexianbin/golib/logger"
+)
+
+// plan compute what mirror would do for the repo without side effects, and record it in repoResult:
+// the dst repo would be created or its metadata updated, and the refs would be pushed or deleted.
+// the refs are selected and pruned like the push of dstGitClient, from the listed refs of src and dst
+func (m *Mirror) plan(srcRepo *Repository, dstRepoName string, repoResult *RepoResult) error {
+	var dstRepo *Repository
+	if m.dstPlainGit != nil {
+		dstRepo = m.dstPlainGit.Repository(dstRepoName)
+	} else if repo, ok := m.dstReposMap[dstRepoName]; ok {
+		dstRepo = repo
+		if repoInfoChanged(dstRepo, srcRepo, m.DstGit) {
+			repoResult.UpdatedFields = updatedFields(dstRepo, srcRepo, m.DstGit)
+		}
+	} else {
+		repoResult.UpdatedFields = []string{"created"}
+	}
+
+	srcRefs, err := m.srcGitClient.ListRemoteRefs(GitURL(srcRepo, m.srcGitClient.GitAuthType))
+	if err != nil {
+		return err
+	}
+	if branches, tags := splitBranchesAndTag(srcRefs); len(branches) == 0 && len(tags) == 0 {
+		return ErrEmptyRepository
+	}
+
+	// the new created repo is empty, the plain git repo not exist is created by push
+	dstRefs := map[string]string{}
+	if dstRepo != nil {
+		dstRefs, err = m.dstGitClient.ListRemoteRefs(GitURL(dstRepo, m.dstGitClient.GitAuthType))
+		if err != nil {
+			if m.dstPlainGit == nil || !isRepositoryNotFound(err) {
+				return err
+			}
+			logger.Debugf("plain git repo %s not exist: %s", repoResult.DstFullName, err.Error())
+			repoResult.UpdatedFields = []string{"created"}
+			dstRefs = map[string]string{}
+		}
+	}
+
+	targets, err := m.dstGitClient.mirrorTargets(srcRefs)
+	if err != nil {
+		return err
+	}
+	repoResult.PushedRefs = diffRefs(targets, dstRefs)
+	repoResult.DeletedRefs = m.dstGitClient.refsToPrune(targets, dstRefs)
+
+	return nil
+}
+
+// isRepositoryNotFound check the err of listing remote refs is the repository not exist, the message of
+// go-git and git cli are different
+func isRepositoryNotFound(err error) bool {
+	if errors.Is(err, transport.ErrRepositoryNotFound) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, transport.ErrRepositoryNotFound.Error()) ||
+		strings.Contains(msg, "does not appear to be a git repository")
+}
+
+// diffRefs return the refs in src, which are not in dst or have different hash
+func diffRefs(src, dst map[string]string) []string {
+	var refs []string
+	for name, hash := range src {
+		if dst[name] != hash {
+			refs = append(refs, name)
+		}
+	}
+	sort.Strings(refs)
+
+	return refs
+}
+
+// printPlan print the plan of every repo
+func printPlan(result *Result) {
+	logger.Printf("mirror plan:")
+	for _, repo := range result.Repos {
+		switch repo.Status {
+		case RepoSkipped:
+			logger.Printf("  %s: skip, %s", repo.SrcFullName, repo.Reason)
+			continue
+		case RepoEmpty:
+			logger.Printf("  %s: skip, %s", repo.SrcFullName, ErrEmptyRepository.Error())
+			continue
+		case RepoFailed:
+			logger.Printf("  %s => %s: fail, %s", repo.SrcFullName, repo.DstFullName, repo.Err.Error())
+			continue
+		}
+
+		logger.Printf("  %s => %s", repo.SrcFullName, repo.DstFullName)
+		if len(repo.UpdatedFields) == 1 && repo.UpdatedFields[0] == "created" {
+			logger.Printf("    create repo")
+		} else if len(repo.UpdatedFields) > 0 {
+			logger.Printf("    update repo info: %s", strings.Join(repo.UpdatedFields, ", "))
+		}
+		for _, ref := range repo.PushedRefs {
+			logger.Printf("    push %s", ref)
+		}
+		for _, ref := range repo.DeletedRefs {
+			logger.Printf("    delete %s", ref)
+		}
+		if len(repo.UpdatedFields) == 0 && len(repo.PushedRefs) == 0 && len(repo.DeletedRefs) == 0 {
+			logger.Printf("    up-to-date")
+		}
+	}
+	for _, orphan := range result.Orphans {
+		logger.Printf("  %s: %s, not in source", orphan.DstFullName, orphan.Action)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+func TestMirror_DryRun(t *testing.T) {
+	tmp := t.TempDir()
+	m := newTestMirror(t, tmp, "repo")
+	m.DryRun = true
+
+	result, err := m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "backup")); !os.IsNotExist(err) {
+		t.Fatal("expect dst repo is not created in dry-run")
+	}
+	plan := result.Repos[0]
+	if len(plan.UpdatedFields) != 1 || plan.UpdatedFields[0] != "created" {
+		t.Errorf("expect repo would be created, got %v", plan.UpdatedFields)
+	}
+	if len(plan.PushedRefs) != 1 || plan.PushedRefs[0] != "refs/heads/master" {
+		t.Errorf("expect master would be pushed, got %v", plan.PushedRefs)
+	}
+
+	// mirror it, then add a branch in source and delete master in destination
+	m = newTestMirror(t, tmp)
+	m.SrcRepos = []string{"repo"}
+	if _, err := m.Do(); err != nil {
+		t.Fatal(err)
+	}
+	srcRepo, _ := git.PlainOpen(filepath.Join(tmp, "src", "repo"))
+	head, _ := srcRepo.Head()
+	if err := srcRepo.Storer.SetReference(plumbing.NewHashReference("refs/heads/dev", head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+	dstRepo, _ := git.PlainOpen(filepath.Join(tmp, "backup", "repo.git"))
+	// the extra refs and the legacy remote-tracking refs are pruned too
+	for _, name := range []string{"refs/tags/old", "refs/notes/commits", "refs/remotes/origin/old"} {
+		if err := dstRepo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), head.Hash())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m.DryRun = true
+	m.ExtraRefs = []string{"refs/notes/*"}
+	result, err = m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan = result.Repos[0]
+	if len(plan.UpdatedFields) != 0 {
+		t.Errorf("expect repo info is up-to-date, got %v", plan.UpdatedFields)
+	}
+	if len(plan.PushedRefs) != 1 || plan.PushedRefs[0] != "refs/heads/dev" {
+		t.Errorf("expect dev would be pushed, got %v", plan.PushedRefs)
+	}
+	if deleted := strings.Join(plan.DeletedRefs, ","); deleted != "refs/notes/commits,refs/remotes/origin/old,refs/tags/old" {
+		t.Errorf("expect notes, legacy remote ref and tag old would be deleted, got %v", plan.DeletedRefs)
+	}
+}
+
+func TestMirror_DryRun_PlainGit(t *testing.T) {
+	for _, backend := range []string{constants.GitBackendGoGit, constants.GitBackendCLI} {
+		tmp := t.TempDir()
+		m := newTestMirror(t, tmp, "repo")
+		m.DstGit = constants.GIT
+		m.DstOrg = "backup"
+		m.DstURLTemplate = "file://" + filepath.ToSlash(tmp) + "/{org}/{name}.git"
+		m.GitBackend = backend
+		m.DryRun = true
+
+		result, err := m.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan := result.Repos[0]
+		if plan.Err != nil {
+			t.Fatalf("expect plain git repo not exist is planned by %s, got %v", backend, plan.Err)
+		}
+		if len(plan.UpdatedFields) != 1 || plan.UpdatedFields[0] != "created" {
+			t.Errorf("expect repo would be created by %s, got %v", backend, plan.UpdatedFields)
+		}
+		if len(plan.PushedRefs) != 1 || plan.PushedRefs[0] != "refs/heads/master" {
+			t.Errorf("expect master would be pushed by %s, got %v", backend, plan.PushedRefs)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xiexianbin/golib/logger"
+)
+
+const (
+	// rateLimitReserve pause the requests until reset when the remaining quota is not more than it
+	rateLimitReserve = 10
+	// maxRateLimitPause is the max pause of a request, github resets the quota every hour
+	maxRateLimitPause = time.Hour
+)
+
+// APIQuota is the rate limit quota of API, reported by the response headers
+type APIQuota struct {
+	Name      string    `json:"name"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+func (q *APIQuota) String() string {
+	return fmt.Sprintf("%s API quota: remaining %d/%d, reset at %s", q.Name, q.Remaining, q.Limit,
+		q.Reset.Local().Format(time.RFC3339))
+}
+
+// quotaAPI is the IGitAPI which tracks its rate limit quota
+type quotaAPI interface {
+	// Quota return the last known quota, nil if unknown
+	Quota() *APIQuota
+}
+
+// rateLimit track the quota of API by the response headers `X-RateLimit-Limit`, `X-RateLimit-Remaining`,
+// `X-RateLimit-Reset` and `Retry-After`, and delay the requests when the quota is nearly used up:
+// slow down to spread the requests until reset when less than 10% quota remains, and pause until reset
+// when rateLimitReserve remains. it is safe for concurrent use
+type rateLimit struct {
+	mu         sync.Mutex
+	quota      APIQuota
+	known      bool      // the quota headers are received
+	retryAfter time.Time // no request before it, asked by `Retry-After`
+}
+
+func newRateLimit(name string) *rateLimit {
+	return &rateLimit{quota: APIQuota{Name: name}}
+}
+
+// parseRateLimitReset parse `X-RateLimit-Reset`, github is the unix epoch seconds, others may be the seconds to reset
+func parseRateLimitReset(value string, now time.Time) (time.Time, bool) {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if v > 1e9 {
+		return time.Unix(v, 0), true
+	}
+	return now.Add(time.Duration(v) * time.Second), true
+}
+
+// parseRetryAfter parse `Retry-After`, the seconds or http date to wait
+func parseRetryAfter(value string, now time.Time) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if v, err := strconv.Atoi(value); err == nil {
+		return now.Add(time.Duration(v) * time.Second), true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// update record the quota of resp
+func (l *rateLimit) update(resp *http.Response, now time.Time) {
+	h := resp.Header
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if v, err := strconv.Atoi(h.Get("X-RateLimit-Limit")); err == nil {
+		l.quota.Limit = v
+	}
+	if v, err := strconv.Atoi(h.Get("X-RateLimit-Remaining")); err == nil {
+		l.quota.Remaining, l.known = v, true
+	}
+	if t, ok := parseRateLimitReset(h.Get("X-RateLimit-Reset"), now); ok {
+		l.quota.Reset = t
+	}
+	if t, ok := parseRetryAfter(h.Get("Retry-After"), now); ok {
+		l.retryAfter = t
+		logger.Warnf("%s API asks to retry after %s", l.quota.Name, t.Sub(now))
+	}
+	if l.known {
+		logger.Debugf("%s", &l.quota)
+	}
+}
+
+// delay return the duration to wait before next request, and whether it is a pause until reset
+func (l *rateLimit) delay(now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var d time.Duration
+	pause := false
+	if l.retryAfter.After(now) {
+		d, pause = l.retryAfter.Sub(now), true
+	}
+	if l.known && l.quota.Reset.After(now) {
+		untilReset := l.quota.Reset.Sub(now)
+		if l.quota.Remaining <= rateLimitReserve {
+			if untilReset+time.Second > d {
+				d, pause = untilReset+time.Second, true
+			}
+		} else if l.quota.Limit > 0 && l.quota.Remaining < l.quota.Limit/10 {
+			if spread := untilReset / time.Duration(l.quota.Remaining); spread > d {
+				d = spread
+			}
+		}
+	}
+	if d > maxRateLimitPause {
+		d = maxRateLimitPause
+	}
+
+	return d, pause
+}
+
+// wait sleep before next request by the quota
+func (l *rateLimit) wait() {
+	d, pause := l.delay(time.Now())
+	if d <= 0 {
+		return
+	}
+	if pause {
+		logger.Warnf("%s, pause %s", l.Quota(), d)
+	} else {
+		logger.Debugf("%s, slow down %s", l.Quota(), d)
+	}
+	time.Sleep(d)
+}
+
+// Quota return the last known quota, nil if unknown
+func (l *rateLimit) Quota() *APIQuota {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.known {
+		return nil
+	}
+	quota := l.quota
+	return &quota
+}
+
+// rateLimitTransport wait for the rateLimit before every request, and update it by the response
+type rateLimitTransport struct {
+	base  http.RoundTripper
+	limit *rateLimit
+}
+
+// newRateLimitClient return a copy of client, nil is http.DefaultClient, which requests are limited by limit
+func newRateLimitClient(client *http.Client, limit *rateLimit) *http.Client {
+	c := &http.Client{}
+	if client != nil {
+		*c = *client
+	}
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.Transport = &rateLimitTransport{base: base, limit: limit}
+
+	return c
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.limit.wait()
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.limit.update(resp, time.Now())
+	}
+	return resp, err
+}
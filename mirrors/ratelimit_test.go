@@ -0,0 +1,102 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func TestRateLimit_Delay(t *testing.T) {
+	now := time.Now()
+	newResp := func(limit, remaining int, reset, retryAfter string) *http.Response {
+		h := http.Header{}
+		h.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		h.Set("X-RateLimit-Reset", reset)
+		if retryAfter != "" {
+			h.Set("Retry-After", retryAfter)
+		}
+		return &http.Response{Header: h}
+	}
+	epoch := func(d time.Duration) string {
+		return strconv.FormatInt(now.Add(d).Unix(), 10)
+	}
+
+	cases := []struct {
+		name  string
+		resp  *http.Response
+		min   time.Duration
+		max   time.Duration
+		pause bool
+	}{
+		{"enough quota", newResp(5000, 4000, epoch(time.Hour), ""), 0, 0, false},
+		{"slow down", newResp(5000, 100, epoch(100*time.Second), ""), 900 * time.Millisecond, time.Second, false},
+		{"pause until reset", newResp(5000, 5, epoch(time.Minute), ""), 59 * time.Second, 61 * time.Second, true},
+		{"reset in seconds", newResp(100, 0, "30", ""), 30 * time.Second, 31 * time.Second, true},
+		{"reset is passed", newResp(5000, 0, epoch(-time.Minute), ""), 0, 0, false},
+		{"retry after", newResp(5000, 4000, epoch(time.Hour), "20"), 20 * time.Second, 20 * time.Second, true},
+	}
+	for _, c := range cases {
+		l := newRateLimit("github")
+		l.update(c.resp, now)
+		d, pause := l.delay(now)
+		if d < c.min || d > c.max || pause != c.pause {
+			t.Errorf("%s: expect delay in [%s, %s] pause %v, got %s %v", c.name, c.min, c.max, c.pause, d, pause)
+		}
+	}
+
+	if l := newRateLimit("gitee"); l.Quota() != nil {
+		t.Error("expect unknown quota without headers")
+	}
+
+	// the retry of secondary rate limit err waits as github asks
+	wait := 90 * time.Second
+	if d := retryAfter(fmt.Errorf("list repos err: %w", &github.AbuseRateLimitError{RetryAfter: &wait})); d != wait {
+		t.Errorf("expect retry after %s, got %s", wait, d)
+	}
+}
+
+func TestGithubAPI_Quota(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Unix()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[]`)
+	}))
+	defer ts.Close()
+
+	c, err := NewGithubAPI(ts.URL+"/api/v3/", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := newRateLimitedAPI(c, 0, nil)
+	if _, err := api.RepositoriesByOrg("org"); err != nil {
+		t.Fatal(err)
+	}
+
+	quota := api.Quota()
+	if quota == nil || quota.Limit != 5000 || quota.Remaining != 4321 || quota.Reset.Unix() != reset {
+		t.Errorf("unexpected quota %+v", quota)
+	}
+}
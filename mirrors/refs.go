@@ -0,0 +1,263 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/xiexianbin/golib/logger"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+// RefFilter select the branches and tags to mirror by glob patterns of their short names, like `main`, `release/*`
+// or `v*`, the empty include patterns select all, and the exclude patterns have higher priority.
+// the excluded refs in destination are left untouched by prune, unless PruneExcluded is true
+type RefFilter struct {
+	IncludeBranches []string
+	ExcludeBranches []string
+	IncludeTags     []string
+	ExcludeTags     []string
+	PruneExcluded   bool
+}
+
+// Validate check the glob patterns
+func (f *RefFilter) Validate() error {
+	for _, patterns := range [][]string{f.IncludeBranches, f.ExcludeBranches, f.IncludeTags, f.ExcludeTags} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid ref pattern %s: %s", pattern, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// IsEmpty return true if all refs are selected
+func (f *RefFilter) IsEmpty() bool {
+	return f == nil ||
+		len(f.IncludeBranches) == 0 && len(f.ExcludeBranches) == 0 && len(f.IncludeTags) == 0 && len(f.ExcludeTags) == 0
+}
+
+// Match check the ref is selected, the refs other than branches and tags are always selected
+func (f *RefFilter) Match(name plumbing.ReferenceName) bool {
+	if f.IsEmpty() {
+		return true
+	}
+
+	switch {
+	case name.IsBranch():
+		return matchRefPatterns(name.Short(), f.IncludeBranches, f.ExcludeBranches)
+	case name.IsTag():
+		return matchRefPatterns(name.Short(), f.IncludeTags, f.ExcludeTags)
+	default:
+		return true
+	}
+}
+
+// shouldPrune check the ref in destination should be deleted, srcRefs are the mirrored refs of source keyed by
+// the name in destination. the refs not mirrored from source are deleted if they are selected by filter,
+// or by PruneExcluded
+func (f *RefFilter) shouldPrune(name plumbing.ReferenceName, srcRefs map[string]string) bool {
+	if _, ok := srcRefs[name.String()]; ok {
+		return false
+	}
+	return f.Match(name) || f.PruneExcluded
+}
+
+// RefRenames is the ordered rules to rename the refs in destination, every rule is a refspec `<src>:<dst>`
+// with optional `*`, like `refs/heads/main:refs/heads/master` or `refs/tags/*:refs/tags/upstream/*`,
+// the first matched rule is applied, the refs without matched rule keep the name
+type RefRenames []config.RefSpec
+
+// NewRefRenames parse and validate the rename rules
+func NewRefRenames(rules []string) (RefRenames, error) {
+	var renames RefRenames
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		refSpec := config.RefSpec(rule)
+		if err := refSpec.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid ref rename %s: %s", rule, err.Error())
+		}
+		if refSpec.IsForceUpdate() || refSpec.IsDelete() ||
+			!strings.HasPrefix(refSpec.Src(), "refs/") || !strings.HasPrefix(refSpec.Dst(plumbing.ReferenceName(refSpec.Src())).String(), "refs/") {
+			return nil, fmt.Errorf("invalid ref rename %s: expect `refs/<src>:refs/<dst>`", rule)
+		}
+		renames = append(renames, refSpec)
+	}
+
+	return renames, nil
+}
+
+// Apply return the name in destination of ref
+func (r RefRenames) Apply(name plumbing.ReferenceName) plumbing.ReferenceName {
+	for _, refSpec := range r {
+		if refSpec.Match(name) {
+			return refSpec.Dst(name)
+		}
+	}
+
+	return name
+}
+
+// mirroredRefs return the refs selected by filter and keyed by the renamed name, the refs is map of name to hash.
+// it is an error if two refs are renamed to the same name
+func mirroredRefs(refs map[string]string, filter *RefFilter, renames RefRenames) (map[string]string, error) {
+	if filter.IsEmpty() && len(renames) == 0 {
+		return refs, nil
+	}
+
+	mirrored := make(map[string]string, len(refs))
+	sources := make(map[string]string, len(refs))
+	for _, name := range sortedRefNames(refs) {
+		if !filter.Match(plumbing.ReferenceName(name)) {
+			continue
+		}
+		dstName := renames.Apply(plumbing.ReferenceName(name)).String()
+		if src, ok := sources[dstName]; ok {
+			return nil, fmt.Errorf("refs %s and %s are both renamed to %s", src, name, dstName)
+		}
+		sources[dstName] = name
+		mirrored[dstName] = refs[name]
+	}
+
+	return mirrored, nil
+}
+
+func sortedRefNames(refs map[string]string) []string {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// pushRefSpecs return the refspecs to push the local refs selected by filter and renamed by renames, the explicit
+// refspec of every ref is used instead of the glob refspecs if filter or renames is not empty
+func pushRefSpecs(repo *git.Repository, refSpecs []config.RefSpec, filter *RefFilter, renames RefRenames) ([]config.RefSpec, error) {
+	if filter.IsEmpty() && len(renames) == 0 {
+		return refSpecs, nil
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []config.RefSpec
+	sources := make(map[plumbing.ReferenceName]plumbing.ReferenceName)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || !filter.Match(ref.Name()) {
+			return nil
+		}
+		for _, refSpec := range refSpecs {
+			if refSpec.Match(ref.Name()) {
+				dstName := renames.Apply(refSpec.Dst(ref.Name()))
+				if src, ok := sources[dstName]; ok {
+					return fmt.Errorf("refs %s and %s are both renamed to %s", src, ref.Name(), dstName)
+				}
+				sources[dstName] = ref.Name()
+				selected = append(selected, config.RefSpec(ref.Name().String()+":"+dstName.String()))
+				break
+			}
+		}
+		return nil
+	})
+	sort.Slice(selected, func(i, j int) bool { return selected[i] < selected[j] })
+
+	return selected, err
+}
+
+// rejectedRefNamespaces is the ref namespaces managed by the forge, which refuse to be pushed
+var rejectedRefNamespaces = map[string][]string{
+	constants.GITHUB:          {"refs/pull/"},
+	constants.GITEE:           {"refs/pull/"},
+	constants.GITEA:           {"refs/pull/"},
+	constants.FORGEJO:         {"refs/pull/"},
+	constants.CODEBERG:        {"refs/pull/"},
+	constants.GITLAB:          {"refs/merge-requests/", "refs/pipelines/", "refs/environments/", "refs/keep-around/"},
+	constants.BITBUCKET:       {"refs/pull-requests/"},
+	constants.BITBUCKETSERVER: {"refs/pull-requests/"},
+}
+
+// NewExtraRefSpecs return the refspecs of extra ref namespaces besides branches and tags, like `refs/notes/*`,
+// `refs/pull/*/head` or `refs/changes/*`, the namespaces rejected by the dst forge are dropped with warning
+func NewExtraRefSpecs(namespaces []string, dst string) ([]config.RefSpec, error) {
+	var refSpecs []config.RefSpec
+	for _, namespace := range namespaces {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" {
+			continue
+		}
+
+		refSpec := config.RefSpec(namespace + ":" + namespace)
+		if err := refSpec.Validate(); err != nil || !strings.HasPrefix(namespace, "refs/") {
+			return nil, fmt.Errorf("invalid extra ref namespace %s, expect like `refs/notes/*`", namespace)
+		}
+		for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+			if strings.HasPrefix(namespace, prefix) {
+				return nil, fmt.Errorf("extra ref namespace %s is mirrored by default", namespace)
+			}
+		}
+
+		if rejected, ok := rejectedNamespace(namespace, dst); ok {
+			logger.Warnf("skip extra ref namespace %s, %s rejects to push %s*", namespace, dst, rejected)
+			continue
+		}
+		refSpecs = append(refSpecs, refSpec)
+	}
+
+	return refSpecs, nil
+}
+
+// rejectedNamespace check the namespace is rejected by the dst forge, return the rejected prefix
+func rejectedNamespace(namespace, dst string) (string, bool) {
+	for _, prefix := range rejectedRefNamespaces[dst] {
+		if strings.HasPrefix(namespace, prefix) || strings.HasPrefix(prefix, strings.TrimSuffix(namespace, "*")) {
+			return prefix, true
+		}
+	}
+
+	return "", false
+}
+
+func matchRefPatterns(name string, includes, excludes []string) bool {
+	matchAny := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(includes) > 0 && !matchAny(includes) {
+		return false
+	}
+	return !matchAny(excludes)
+}
@@ -0,0 +1,251 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"path/filepath"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+func TestRefFilter_Match(t *testing.T) {
+	f := &RefFilter{
+		IncludeBranches: []string{"main", "release/*"},
+		ExcludeBranches: []string{"release/old-*"},
+		ExcludeTags:     []string{"*-rc*"},
+	}
+	if err := f.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[plumbing.ReferenceName]bool{
+		"refs/heads/main":             true,
+		"refs/heads/dev":              false,
+		"refs/heads/release/1.0":      true,
+		"refs/heads/release/old-1.0":  false,
+		"refs/tags/v1.0":              true,
+		"refs/tags/v1.1-rc1":          false,
+		"refs/remotes/origin/feature": true,
+	}
+	for name, expected := range cases {
+		if got := f.Match(name); got != expected {
+			t.Errorf("match %s expect %v, got %v", name, expected, got)
+		}
+	}
+
+	var empty *RefFilter
+	if !empty.IsEmpty() || !empty.Match("refs/heads/dev") {
+		t.Error("expect nil filter select all refs")
+	}
+	if err := (&RefFilter{IncludeTags: []string{"v[1"}}).Validate(); err == nil {
+		t.Error("expect invalid pattern error")
+	}
+}
+
+func TestMirror_Do_RefFilter(t *testing.T) {
+	tmp := t.TempDir()
+	m := newTestMirror(t, tmp, "repo")
+	if _, err := m.Do(); err != nil {
+		t.Fatal(err)
+	}
+
+	// add branches dev and feature in source, and branch local in destination
+	srcRepo, _ := git.PlainOpen(filepath.Join(tmp, "src", "repo"))
+	head, _ := srcRepo.Head()
+	for _, name := range []string{"refs/heads/dev", "refs/heads/feature"} {
+		if err := srcRepo.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), head.Hash())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dstRepo, _ := git.PlainOpen(filepath.Join(tmp, "backup", "repo.git"))
+	if err := dstRepo.Storer.SetReference(plumbing.NewHashReference("refs/heads/local", head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+
+	m = newTestMirror(t, tmp)
+	m.SrcRepos = []string{"repo"}
+	m.RefFilter = &RefFilter{ExcludeBranches: []string{"feature", "local"}}
+	result, err := m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pushed := result.Repos[0].PushedRefs; len(pushed) == 0 || pushed[0] != "refs/heads/dev" {
+		t.Errorf("expect dev is pushed, got %v", pushed)
+	}
+	if _, err := dstRepo.Reference("refs/heads/feature", false); err == nil {
+		t.Error("expect excluded branch feature is not pushed")
+	}
+	if _, err := dstRepo.Reference("refs/heads/local", false); err != nil {
+		t.Errorf("expect excluded branch local is untouched: %s", err)
+	}
+
+	// delete the excluded branch in destination by PruneExcluded
+	m = newTestMirror(t, tmp)
+	m.SrcRepos = []string{"repo"}
+	m.RefFilter = &RefFilter{ExcludeBranches: []string{"feature", "local"}, PruneExcluded: true}
+	result, err = m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted := result.Repos[0].DeletedRefs
+	if len(deleted) != 1 || deleted[0] != "refs/heads/local" {
+		t.Errorf("expect excluded branch local is deleted, got %v", deleted)
+	}
+	if _, err := dstRepo.Reference("refs/heads/master", false); err != nil {
+		t.Errorf("expect master is kept: %s", err)
+	}
+}
+
+func TestRefRenames_Apply(t *testing.T) {
+	renames, err := NewRefRenames([]string{"refs/heads/main:refs/heads/master", " refs/tags/*:refs/tags/upstream/* ", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[plumbing.ReferenceName]plumbing.ReferenceName{
+		"refs/heads/main": "refs/heads/master",
+		"refs/heads/dev":  "refs/heads/dev",
+		"refs/tags/v1.2":  "refs/tags/upstream/v1.2",
+	}
+	for name, expected := range cases {
+		if got := renames.Apply(name); got != expected {
+			t.Errorf("rename %s expect %s, got %s", name, expected, got)
+		}
+	}
+
+	for _, rule := range []string{"refs/heads/*:refs/heads/master", "+refs/heads/a:refs/heads/b", ":refs/heads/a", "main:master"} {
+		if _, err := NewRefRenames([]string{rule}); err == nil {
+			t.Errorf("expect invalid rule %s err", rule)
+		}
+	}
+
+	refs := map[string]string{"refs/heads/main": "1", "refs/heads/master": "2"}
+	if _, err := mirroredRefs(refs, nil, renames); err == nil {
+		t.Error("expect renamed refs collision err")
+	}
+}
+
+func TestMirror_Do_RefRenames(t *testing.T) {
+	tmp := t.TempDir()
+	m := newTestMirror(t, tmp, "repo")
+	m.RefRenames = []string{"refs/heads/master:refs/heads/main", "refs/tags/*:refs/tags/upstream/*"}
+
+	srcRepo, _ := git.PlainOpen(filepath.Join(tmp, "src", "repo"))
+	head, _ := srcRepo.Head()
+	if err := srcRepo.Storer.SetReference(plumbing.NewHashReference("refs/tags/v1.2", head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+
+	// mirror twice, the renamed refs are up-to-date and not deleted by prune
+	for _, status := range []RepoStatus{RepoSuccess, RepoUpToDate} {
+		result, err := m.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Repos[0].Status != status || len(result.Repos[0].DeletedRefs) != 0 {
+			t.Fatalf("expect %s, got %s, deleted %v", status, result, result.Repos[0].DeletedRefs)
+		}
+	}
+
+	dstRepo, _ := git.PlainOpen(filepath.Join(tmp, "backup", "repo.git"))
+	for _, name := range []plumbing.ReferenceName{"refs/heads/main", "refs/tags/upstream/v1.2"} {
+		if _, err := dstRepo.Reference(name, false); err != nil {
+			t.Errorf("expect %s is pushed: %s", name, err)
+		}
+	}
+	for _, name := range []plumbing.ReferenceName{"refs/heads/master", "refs/tags/v1.2"} {
+		if _, err := dstRepo.Reference(name, false); err == nil {
+			t.Errorf("expect %s is not pushed", name)
+		}
+	}
+
+	m.DryRun = true
+	result, err := m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan := result.Repos[0]; len(plan.PushedRefs) != 0 || len(plan.DeletedRefs) != 0 {
+		t.Errorf("expect up-to-date plan, got push %v delete %v", plan.PushedRefs, plan.DeletedRefs)
+	}
+}
+
+func TestNewExtraRefSpecs(t *testing.T) {
+	namespaces := []string{"refs/notes/*", "refs/pull/*/head", "refs/changes/*"}
+	refSpecs, err := NewExtraRefSpecs(namespaces, constants.FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refSpecs) != 3 || refSpecs[1] != "refs/pull/*/head:refs/pull/*/head" {
+		t.Errorf("unexpected refspecs %v", refSpecs)
+	}
+
+	// github rejects refs/pull/*
+	refSpecs, err = NewExtraRefSpecs(namespaces, constants.GITHUB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refSpecs) != 2 || refSpecs[0] != "refs/notes/*:refs/notes/*" || refSpecs[1] != "refs/changes/*:refs/changes/*" {
+		t.Errorf("expect refs/pull/*/head is skipped, got %v", refSpecs)
+	}
+
+	for _, namespace := range []string{"notes/*", "refs/*/*", "refs/heads/*", "refs/a:refs/b"} {
+		if _, err := NewExtraRefSpecs([]string{namespace}, constants.FILE); err == nil {
+			t.Errorf("expect invalid namespace %s err", namespace)
+		}
+	}
+}
+
+func TestMirror_Do_ExtraRefs(t *testing.T) {
+	tmp := t.TempDir()
+	newTestSourceRepo(t, filepath.Join(tmp, "src", "repo"))
+	srcRepo, _ := git.PlainOpen(filepath.Join(tmp, "src", "repo"))
+	head, _ := srcRepo.Head()
+	extraRefs := []plumbing.ReferenceName{"refs/notes/commits", "refs/pull/1/head"}
+	for _, name := range extraRefs {
+		if err := srcRepo.Storer.SetReference(plumbing.NewHashReference(name, head.Hash())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := newTestMirror(t, tmp)
+	m.SrcRepos = []string{"repo"}
+	if _, err := m.Do(); err != nil {
+		t.Fatal(err)
+	}
+	dstRepo, _ := git.PlainOpen(filepath.Join(tmp, "backup", "repo.git"))
+	for _, name := range extraRefs {
+		if _, err := dstRepo.Reference(name, false); err == nil {
+			t.Errorf("expect %s is not mirrored by default", name)
+		}
+	}
+
+	m.ExtraRefs = []string{"refs/notes/*", "refs/pull/*/head"}
+	result, err := m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Success != 1 {
+		t.Fatalf("unexpected result %s", result)
+	}
+	for _, name := range extraRefs {
+		if _, err := dstRepo.Reference(name, false); err != nil {
+			t.Errorf("expect %s is mirrored: %s", name, err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+type jsonReportRepo struct {
+	*RepoResult
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+type jsonReportOrphan struct {
+	*OrphanResult
+	Error string `json:"error,omitempty"`
+}
+
+type jsonReport struct {
+	Success         int                 `json:"success"`
+	Failed          int                 `json:"failed"`
+	Skipped         int                 `json:"skipped"`
+	Empty           int                 `json:"empty"`
+	UpToDate        int                 `json:"up_to_date"`
+	DurationSeconds float64             `json:"duration_seconds"`
+	APIQuotas       []*APIQuota         `json:"api_quotas,omitempty"`
+	Repos           []*jsonReportRepo   `json:"repos"`
+	Orphans         []*jsonReportOrphan `json:"orphans,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name         `xml:"testsuite"`
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      float64          `xml:"time,attr"`
+	TestCases []*junitTestCase `xml:"testcase"`
+}
+
+// WriteReport write result to file in format json or junit
+func WriteReport(result *Result, format, path string) error {
+	var content []byte
+	var err error
+	switch format {
+	case constants.ReportFormatJSON:
+		content, err = jsonReportContent(result)
+	case constants.ReportFormatJUnit:
+		content, err = junitReportContent(result)
+	default:
+		return fmt.Errorf("un-support report format %s", format)
+	}
+	if err != nil {
+		return fmt.Errorf("encode %s report err: %s", format, err.Error())
+	}
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("write report %s err: %s", path, err.Error())
+	}
+
+	return nil
+}
+
+func jsonReportContent(result *Result) ([]byte, error) {
+	report := &jsonReport{
+		Success:         result.Success,
+		Failed:          result.Failed,
+		Skipped:         result.Skipped,
+		Empty:           result.Empty,
+		UpToDate:        result.UpToDate,
+		DurationSeconds: result.Duration.Seconds(),
+		APIQuotas:       result.APIQuotas,
+		Repos:           make([]*jsonReportRepo, len(result.Repos)),
+	}
+	for i, repo := range result.Repos {
+		report.Repos[i] = &jsonReportRepo{RepoResult: repo, DurationSeconds: repo.Duration.Seconds()}
+		if repo.Err != nil {
+			report.Repos[i].Error = repo.Err.Error()
+		}
+	}
+	for _, orphan := range result.Orphans {
+		reportOrphan := &jsonReportOrphan{OrphanResult: orphan}
+		if orphan.Err != nil {
+			reportOrphan.Error = orphan.Err.Error()
+		}
+		report.Orphans = append(report.Orphans, reportOrphan)
+	}
+
+	return json.MarshalIndent(report, "", "  ")
+}
+
+// junitReportContent every repo is a test case, failed repo is failure, skipped and empty repo is skipped.
+// the orphan repos are test cases too, failed to archive, make private or delete is failure
+func junitReportContent(result *Result) ([]byte, error) {
+	suite := &junitTestSuite{
+		Name:     "git-mirrors",
+		Tests:    len(result.Repos) + len(result.Orphans),
+		Failures: result.Failed + result.OrphanFailed,
+		Skipped:  result.Skipped + result.Empty,
+		Time:     result.Duration.Seconds(),
+	}
+	for _, repo := range result.Repos {
+		testCase := &junitTestCase{
+			Name:      repo.SrcFullName,
+			ClassName: repo.DstFullName,
+			Time:      repo.Duration.Seconds(),
+		}
+		switch repo.Status {
+		case RepoFailed:
+			testCase.Failure = &junitMessage{Message: repo.Err.Error(), Content: repo.Err.Error()}
+		case RepoSkipped:
+			testCase.Skipped = &junitMessage{Message: repo.Reason}
+		case RepoEmpty:
+			testCase.Skipped = &junitMessage{Message: ErrEmptyRepository.Error()}
+		}
+
+		var out []string
+		if len(repo.UpdatedFields) > 0 {
+			out = append(out, "updated fields: "+strings.Join(repo.UpdatedFields, ", "))
+		}
+		if len(repo.PushedRefs) > 0 {
+			out = append(out, "pushed refs: "+strings.Join(repo.PushedRefs, ", "))
+		}
+		if len(repo.DeletedRefs) > 0 {
+			out = append(out, "deleted refs: "+strings.Join(repo.DeletedRefs, ", "))
+		}
+		if repo.Status == RepoSuccess {
+			out = append(out, fmt.Sprintf("fetched bytes: %d", repo.FetchedBytes))
+		} else if repo.Status == RepoUpToDate {
+			out = append(out, "up-to-date")
+		}
+		testCase.SystemOut = strings.Join(out, "\n")
+
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	for _, orphan := range result.Orphans {
+		testCase := &junitTestCase{
+			Name:      orphan.DstFullName,
+			ClassName: "orphan",
+			SystemOut: orphan.Action + ", not in source",
+		}
+		if orphan.Err != nil {
+			testCase.Failure = &junitMessage{Message: orphan.Err.Error(), Content: orphan.Err.Error()}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	content, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), content...), nil
+}
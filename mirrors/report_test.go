@@ -0,0 +1,93 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+func newTestResult() *Result {
+	result := &Result{Duration: 3 * time.Second}
+	result.add(&RepoResult{SrcRepo: "a", SrcFullName: "github/org/a", DstFullName: "gitee/org/a", Status: RepoSuccess,
+		PushedRefs: []string{"refs/heads/master"}, DeletedRefs: []string{"refs/tags/v1"}, UpdatedFields: []string{"description"},
+		FetchedBytes: 1024, Duration: time.Second})
+	result.add(&RepoResult{SrcRepo: "b", SrcFullName: "github/org/b", DstFullName: "gitee/org/b", Status: RepoFailed,
+		Err: errors.New("push err")})
+	result.add(&RepoResult{SrcRepo: "c", SrcFullName: "github/org/c", Status: RepoSkipped, Reason: "in black-list"})
+
+	return result
+}
+
+func TestWriteReport_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := WriteReport(newTestResult(), constants.ReportFormatJSON, path); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(path)
+	var report jsonReport
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Success != 1 || report.Failed != 1 || report.Skipped != 1 || len(report.Repos) != 3 {
+		t.Fatalf("unexpected report %s", content)
+	}
+	if report.Repos[0].DeletedRefs[0] != "refs/tags/v1" || report.Repos[0].DurationSeconds != 1 {
+		t.Errorf("unexpected repo %s", content)
+	}
+	if report.Repos[1].Error != "push err" {
+		t.Errorf("expect error of failed repo, got %s", content)
+	}
+}
+
+func TestWriteReport_JUnit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.xml")
+	result := newTestResult()
+	result.addOrphan(&OrphanResult{DstRepo: "d", DstFullName: "gitee/org/d", Action: constants.OrphanDelete,
+		Err: errors.New("403 Forbidden")})
+	if err := WriteReport(result, constants.ReportFormatJUnit, path); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(path)
+	var suite junitTestSuite
+	if err := xml.Unmarshal(content, &suite); err != nil {
+		t.Fatal(err)
+	}
+	if suite.Tests != 4 || suite.Failures != 2 || suite.Skipped != 1 || len(suite.TestCases) != 4 {
+		t.Fatalf("unexpected report %s", content)
+	}
+	if suite.TestCases[1].Failure == nil || suite.TestCases[1].Failure.Message != "push err" {
+		t.Errorf("expect failure of failed repo, got %s", content)
+	}
+	if suite.TestCases[2].Skipped == nil || suite.TestCases[2].Skipped.Message != "in black-list" {
+		t.Errorf("expect skipped repo, got %s", content)
+	}
+	if suite.TestCases[3].Failure == nil || suite.TestCases[3].Failure.Message != "403 Forbidden" {
+		t.Errorf("expect failure of orphan repo, got %s", content)
+	}
+
+	if err := WriteReport(newTestResult(), "html", path); err == nil {
+		t.Error("expect un-support report format err")
+	}
+}
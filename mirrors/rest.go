@@ -0,0 +1,138 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/xiexianbin/golib/logger"
+	"golang.org/x/oauth2"
+)
+
+// restClient is a small JSON REST client shared by the forges without a vendored SDK
+type restClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Context    context.Context
+
+	username string // basic auth, used when the token is like `username:password`
+	password string
+}
+
+// newRESTClient return a restClient, if accessToken is not empty, use it as oauth2 bearer token
+func newRESTClient(ctx context.Context, baseURL, accessToken string) *restClient {
+	httpClient := http.DefaultClient
+	if accessToken != "" {
+		ts := oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: accessToken},
+		)
+		httpClient = oauth2.NewClient(ctx, ts)
+	}
+
+	return &restClient{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: httpClient,
+		Context:    ctx,
+	}
+}
+
+// newBasicAuthRESTClient return a restClient use http basic auth
+func newBasicAuthRESTClient(ctx context.Context, baseURL, username, password string) *restClient {
+	client := newRESTClient(ctx, baseURL, "")
+	client.username, client.password = username, password
+
+	return client
+}
+
+// StatusError is the non 2xx response of restClient
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// do send a request to BaseURL + path, encode body as json and decode the response into out.
+// path may also be an absolute url, like the next page link of a paginated response.
+// A non 2xx status code is returned as err, together with the response
+func (c *restClient) do(method, path string, query url.Values, body, out interface{}) (*http.Response, error) {
+	u := c.BaseURL + path
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		u = path
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(c.Context, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	logger.Debugf("[%s %s]", method, u)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, &StatusError{Method: method, URL: u, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp, fmt.Errorf("decode %s %s response err: %s", method, u, err.Error())
+		}
+	}
+
+	return resp, nil
+}
+
+// isStatus return true if resp is not nil and resp.StatusCode is code
+func isStatus(resp *http.Response, code int) bool {
+	return resp != nil && resp.StatusCode == code
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+// ErrEmptyRepository is returned by mirrorGit when the source repository has no commits
+var ErrEmptyRepository = errors.New("source repository is empty")
+
+type RepoStatus string
+
+const (
+	RepoSuccess RepoStatus = "success"
+	RepoFailed  RepoStatus = "failed"
+	RepoSkipped RepoStatus = "skipped"
+	RepoEmpty   RepoStatus = "empty"
+	// RepoUpToDate the refs of dst repo are same as src repo, fetch and push are skipped
+	RepoUpToDate RepoStatus = "up-to-date"
+)
+
+// RepoResult is the outcome of one source repo
+type RepoResult struct {
+	SrcRepo     string     `json:"src_repo"`
+	DstRepo     string     `json:"dst_repo,omitempty"`
+	SrcFullName string     `json:"src_full_name"`           // like `github/xiexianbin/repo`
+	DstFullName string     `json:"dst_full_name,omitempty"` // like `gitee/xiexianbin/repo`
+	Status      RepoStatus `json:"status"`
+	Reason      string     `json:"reason,omitempty"` // why the repo is skipped
+	Err         error      `json:"-"`
+
+	PushedRefs    []string      `json:"pushed_refs,omitempty"`    // refs created or updated in dst repo
+	DeletedRefs   []string      `json:"deleted_refs,omitempty"`   // refs deleted in dst repo by prune
+	UpdatedFields []string      `json:"updated_fields,omitempty"` // metadata fields updated by mirrorRepoInfo, `created` for new repo
+	FetchedBytes  int64         `json:"fetched_bytes"`            // the growth of cache packfiles after fetch from source
+	Duration      time.Duration `json:"-"`
+
+	srcRefs map[string]string // the refs of src and dst repo listed by mirror, recorded in StateStore
+	dstRefs map[string]string
+}
+
+// Result is the outcome of Mirror.Do
+type Result struct {
+	Repos []*RepoResult
+
+	Success  int
+	Failed   int
+	Skipped  int
+	Empty    int
+	UpToDate int
+
+	Duration  time.Duration
+	DryRun    bool            // the refs and fields of repos are planned, not changed
+	APIQuotas []*APIQuota     // the rate limit quota of src and dst API at the end
+	Orphans   []*OrphanResult // the dst repos whose source is gone, handled by the orphan policy
+
+	OrphanFailed int // the orphan repos failed to archive, make private or delete
+}
+
+// add append a repo result and count it
+func (r *Result) add(repo *RepoResult) {
+	r.Repos = append(r.Repos, repo)
+	switch repo.Status {
+	case RepoSuccess:
+		r.Success += 1
+	case RepoFailed:
+		r.Failed += 1
+	case RepoSkipped:
+		r.Skipped += 1
+	case RepoEmpty:
+		r.Empty += 1
+	case RepoUpToDate:
+		r.UpToDate += 1
+	}
+}
+
+// addOrphan append an orphan result and count it if failed
+func (r *Result) addOrphan(orphan *OrphanResult) {
+	r.Orphans = append(r.Orphans, orphan)
+	if orphan.Err != nil {
+		r.OrphanFailed += 1
+	}
+}
+
+// Merge add the repo results of other, like the result of another job
+func (r *Result) Merge(other *Result) {
+	// the merged result is dry-run only if all the results are dry-run
+	r.DryRun = other.DryRun && (len(r.Repos) == 0 || r.DryRun)
+	for _, repo := range other.Repos {
+		r.add(repo)
+	}
+	r.APIQuotas = append(r.APIQuotas, other.APIQuotas...)
+	for _, orphan := range other.Orphans {
+		r.addOrphan(orphan)
+	}
+}
+
+// Attempted return the number of repos which are not skipped
+func (r *Result) Attempted() int {
+	return len(r.Repos) - r.Skipped
+}
+
+// IsTotalFailure return true if every attempted repo and orphan repo is failed
+func (r *Result) IsTotalFailure() bool {
+	failed := r.Failed + r.OrphanFailed
+	return failed > 0 && failed == r.Attempted()+len(r.Orphans)
+}
+
+// IsFailed check the result with fail-on policy: any, all or none
+func (r *Result) IsFailed(failOn string) (bool, error) {
+	switch failOn {
+	case constants.FailOnAny:
+		return r.Failed > 0 || r.OrphanFailed > 0, nil
+	case constants.FailOnAll:
+		return r.IsTotalFailure(), nil
+	case constants.FailOnNone:
+		return false, nil
+	default:
+		return false, fmt.Errorf("un-support fail-on policy %s", failOn)
+	}
+}
+
+func (r *Result) String() string {
+	s := fmt.Sprintf("success(%d) up-to-date(%d) fail(%d) skip(%d) empty(%d)", r.Success, r.UpToDate, r.Failed, r.Skipped, r.Empty)
+	if len(r.Orphans) > 0 {
+		s += fmt.Sprintf(" orphan(%d)", len(r.Orphans))
+	}
+	if r.OrphanFailed > 0 {
+		s += fmt.Sprintf(" orphan-fail(%d)", r.OrphanFailed)
+	}
+	if r.DryRun {
+		s += " (dry-run)"
+	}
+	return s
+}
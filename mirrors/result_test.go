@@ -0,0 +1,95 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+func TestResult_IsFailed(t *testing.T) {
+	partial := &Result{}
+	partial.add(&RepoResult{SrcRepo: "a", Status: RepoSuccess})
+	partial.add(&RepoResult{SrcRepo: "b", Status: RepoFailed, Err: errors.New("push err")})
+	partial.add(&RepoResult{SrcRepo: "c", Status: RepoSkipped, Reason: "in black-list"})
+
+	total := &Result{}
+	total.add(&RepoResult{SrcRepo: "b", Status: RepoFailed, Err: errors.New("push err")})
+	total.add(&RepoResult{SrcRepo: "c", Status: RepoSkipped, Reason: "in black-list"})
+
+	// the orphan repo failed to delete
+	orphan := &Result{}
+	orphan.add(&RepoResult{SrcRepo: "a", Status: RepoSuccess})
+	orphan.addOrphan(&OrphanResult{DstRepo: "gone", Action: constants.OrphanDelete, Err: errors.New("403 Forbidden")})
+
+	tests := []struct {
+		name   string
+		result *Result
+		failOn string
+		want   bool
+	}{
+		{"partial-any", partial, constants.FailOnAny, true},
+		{"partial-all", partial, constants.FailOnAll, false},
+		{"partial-none", partial, constants.FailOnNone, false},
+		{"total-all", total, constants.FailOnAll, true},
+		{"orphan-any", orphan, constants.FailOnAny, true},
+		{"orphan-all", orphan, constants.FailOnAll, false},
+		{"empty-any", &Result{}, constants.FailOnAny, false},
+		{"empty-all", &Result{}, constants.FailOnAll, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.result.IsFailed(tt.failOn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("IsFailed(%s) = %v, want %v", tt.failOn, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := partial.IsFailed("some"); err == nil {
+		t.Error("expect un-support fail-on policy err")
+	}
+	if partial.String() != "success(1) up-to-date(0) fail(1) skip(1) empty(0)" {
+		t.Errorf("unexpected %s", partial)
+	}
+	if orphan.String() != "success(1) up-to-date(0) fail(0) skip(0) empty(0) orphan(1) orphan-fail(1)" {
+		t.Errorf("unexpected %s", orphan)
+	}
+}
+
+func TestMirror_FailedResult(t *testing.T) {
+	tmp := t.TempDir()
+	m := newTestMirror(t, tmp, "repo")
+	m.DstGit, m.DstOrg = constants.GITHUB, "me"
+	_, err := m.Do()
+	if err == nil {
+		t.Fatal("expect mirror can not start without github token")
+	}
+
+	// the job which can not start is merged as a failed repo
+	total := &Result{}
+	total.Merge(m.FailedResult(err))
+	if total.Failed != 1 || !total.IsTotalFailure() {
+		t.Fatalf("unexpected result %s", total)
+	}
+	if repo := total.Repos[0]; repo.SrcFullName != "git/src" || repo.DstFullName != "github/me" || repo.Err != err {
+		t.Errorf("unexpected failed repo %+v", repo)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/google/go-github/github"
+	"github.com/xiexianbin/golib/logger"
+)
+
+// maxRetryBackoff is the max delay between two attempts
+const maxRetryBackoff = 5 * time.Minute
+
+// RetryPolicy retry the transient failures of git and API operations with exponential backoff and jitter
+type RetryPolicy struct {
+	MaxAttempts int           // the max attempts of an operation, 1 or less is no retry
+	Backoff     time.Duration // the delay before the first retry, doubled for every retry
+	Jitter      float64       // the random factor of delay in [0, 1], 0.2 means the delay is ±20%
+}
+
+// Validate check the policy
+func (p *RetryPolicy) Validate() error {
+	if p.Backoff < 0 {
+		return fmt.Errorf("retry-backoff must not be negative, got %s", p.Backoff)
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("retry-jitter must be in [0, 1], got %f", p.Jitter)
+	}
+	return nil
+}
+
+// delay return the delay before the retry-th retry, which starts from 1
+func (p *RetryPolicy) delay(retry int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < retry && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	if p.Jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 + p.Jitter*(2*rand.Float64()-1)))
+	}
+	return delay
+}
+
+// Do run fn until it succeeds, the err is permanent, or MaxAttempts is reached, return the last err.
+// the delay is extended to the reset of rate limit err. name is the operation shown in log, nil policy run fn once
+func (p *RetryPolicy) Do(name string, fn func() error) error {
+	err := fn()
+	if p == nil {
+		return err
+	}
+	for attempt := 1; err != nil && attempt < p.MaxAttempts && IsRetryable(err); attempt++ {
+		delay := p.delay(attempt)
+		if wait := retryAfter(err); wait > delay {
+			delay = wait
+		}
+		logger.Warnf("%s err: %s, retry %d/%d after %s", name, err.Error(), attempt, p.MaxAttempts-1, delay)
+		time.Sleep(delay)
+		err = fn()
+	}
+	return err
+}
+
+// retryAfter return the delay asked by the rate limit err of github, 0 if unknown
+func retryAfter(err error) time.Duration {
+	var wait time.Duration
+	var rateLimitErr *github.RateLimitError
+	var abuseRateLimitErr *github.AbuseRateLimitError
+	if errors.As(err, &rateLimitErr) {
+		wait = time.Until(rateLimitErr.Rate.Reset.Time) + time.Second
+	} else if errors.As(err, &abuseRateLimitErr) && abuseRateLimitErr.RetryAfter != nil {
+		wait = *abuseRateLimitErr.RetryAfter
+	}
+	if wait > maxRateLimitPause {
+		wait = maxRateLimitPause
+	}
+	return wait
+}
+
+var (
+	// statusCodePattern match the http status code in err message, like `: 502 Bad Gateway` or `HTTP 503`,
+	// but not the port of url, like `host:443/`
+	statusCodePattern = regexp.MustCompile(`(?:^|[\s(])([45]\d\d)(?:[\s:,)]|$)`)
+
+	// permanentMessages are the err messages of git cli and wrapped errors, which are not fixed by retry
+	permanentMessages = []string{
+		"authentication required", "authentication failed", "authorization failed", "bad credentials",
+		"permission denied", "could not read username", "repository not found", "not found",
+		"non-fast-forward", "[rejected]", "[remote rejected]", "fetch first",
+	}
+	// retryableMessages are the err messages of transient network and server failures
+	retryableMessages = []string{
+		"timeout", "timed out", "deadline exceeded", "connection reset", "connection refused", "broken pipe",
+		"unexpected eof", "early eof", "tls handshake", "temporary failure in name resolution",
+		"remote end hung up unexpectedly", "rpc failed",
+	}
+)
+
+// isRetryableStatus return true for the status code of overloaded or failed server, 5xx, 408 and 429
+func isRetryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+// IsRetryable classify err as retryable, like timeouts, 5xx, 429 and connection resets, or permanent,
+// like auth failures, 404 and non-fast-forward. the unknown err is permanent
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// permanent errors
+	if errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed) ||
+		errors.Is(err, transport.ErrRepositoryNotFound) || errors.Is(err, transport.ErrEmptyRemoteRepository) ||
+		errors.Is(err, git.ErrNonFastForwardUpdate) || errors.Is(err, ErrEmptyRepository) {
+		return false
+	}
+
+	// the http status of API
+	var rateLimitErr *github.RateLimitError
+	var abuseRateLimitErr *github.AbuseRateLimitError
+	if errors.As(err, &rateLimitErr) || errors.As(err, &abuseRateLimitErr) {
+		return true
+	}
+	var githubErr *github.ErrorResponse
+	if errors.As(err, &githubErr) && githubErr.Response != nil {
+		return isRetryableStatus(githubErr.Response.StatusCode)
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return isRetryableStatus(statusErr.StatusCode)
+	}
+
+	// network errors
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) ||
+		errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// the messages of git cli, gitee and the errors wrapped as string
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "rate limit") {
+		return true
+	}
+	if match := statusCodePattern.FindStringSubmatch(msg); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		return isRetryableStatus(code)
+	}
+	for _, m := range permanentMessages {
+		if strings.Contains(msg, m) {
+			return false
+		}
+	}
+	for _, m := range retryableMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,143 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/google/go-github/github"
+)
+
+func TestIsRetryable(t *testing.T) {
+	githubErr := func(code int) error {
+		req := &http.Request{Method: http.MethodGet, URL: &url.URL{Scheme: "https", Host: "api.github.com"}}
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: code, Request: req}}
+	}
+
+	cases := map[error]bool{
+		nil:                                 false,
+		githubErr(http.StatusBadGateway):    true,
+		githubErr(http.StatusNotFound):      false,
+		&StatusError{StatusCode: 429}:       true,
+		&StatusError{StatusCode: 401}:       false,
+		context.DeadlineExceeded:            true,
+		syscall.ECONNRESET:                  true,
+		transport.ErrAuthenticationRequired: false,
+		transport.ErrRepositoryNotFound:     false,
+		fmt.Errorf("push remoteName: gitee, err: %w", git.ErrNonFastForwardUpdate):                false,
+		fmt.Errorf("[git fetch origin] err: %w", syscall.ECONNRESET):                              true,
+		errors.New("502 Bad Gateway"):                                                             true,
+		errors.New("[git push] err: exit status 1, error: RPC failed; HTTP 503 curl 22"):          true,
+		errors.New("[git fetch] err: fatal: Authentication failed for 'https://host/'"):           false,
+		errors.New("[git push] err: ! [rejected] main -> main (fetch first)"):                     false,
+		errors.New("[git fetch] err: unable to access 'https://host:443/': Connection timed out"): true,
+		errors.New("You have exceeded a secondary rate limit"):                                    true,
+		errors.New("destination name collides"):                                                   false,
+	}
+	for err, expected := range cases {
+		if got := IsRetryable(err); got != expected {
+			t.Errorf("expect %v is retryable %v, got %v", err, expected, got)
+		}
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, Jitter: 0.5}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the transient err is retried until success
+	attempts := 0
+	err := p.Do("test", func() error {
+		if attempts += 1; attempts < 3 {
+			return syscall.ECONNRESET
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("expect success after 3 attempts, got %d, %v", attempts, err)
+	}
+
+	// the permanent err is not retried
+	attempts = 0
+	err = p.Do("test", func() error {
+		attempts += 1
+		return transport.ErrAuthenticationRequired
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("expect 1 attempt of permanent err, got %d, %v", attempts, err)
+	}
+
+	// the last err is returned when all attempts failed
+	attempts = 0
+	err = p.Do("test", func() error {
+		attempts += 1
+		return &StatusError{StatusCode: http.StatusBadGateway}
+	})
+	if err == nil || attempts != 3 {
+		t.Errorf("expect 3 failed attempts, got %d, %v", attempts, err)
+	}
+
+	for _, d := range []time.Duration{p.delay(1), p.delay(2)} {
+		if d <= 0 || d > 3*time.Millisecond {
+			t.Errorf("unexpected delay %s", d)
+		}
+	}
+	if d := (&RetryPolicy{Backoff: time.Minute}).delay(10); d != maxRetryBackoff {
+		t.Errorf("expect delay is capped to %s, got %s", maxRetryBackoff, d)
+	}
+	if err := (&RetryPolicy{Jitter: 2}).Validate(); err == nil {
+		t.Error("expect invalid jitter err")
+	}
+}
+
+// flakyAPI fail the first calls of RepositoriesByOrg
+type flakyAPI struct {
+	IGitAPI
+	failures int
+	calls    int
+}
+
+func (f *flakyAPI) RepositoriesByOrg(orgName string) ([]*Repository, error) {
+	if f.calls += 1; f.calls <= f.failures {
+		return nil, &StatusError{StatusCode: http.StatusBadGateway}
+	}
+	return f.IGitAPI.RepositoriesByOrg(orgName)
+}
+
+func TestRateLimitedAPI_Retry(t *testing.T) {
+	api, _ := NewFileAPI()
+	flaky := &flakyAPI{IGitAPI: api, failures: 1}
+	r := newRateLimitedAPI(flaky, 0, &RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond})
+	if _, err := r.RepositoriesByOrg(t.TempDir()); err != nil || flaky.calls != 2 {
+		t.Errorf("expect success after retry, got %d calls, %v", flaky.calls, err)
+	}
+
+	flaky = &flakyAPI{IGitAPI: api, failures: 2}
+	r = newRateLimitedAPI(flaky, 0, &RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond})
+	if _, err := r.RepositoriesByOrg(t.TempDir()); err == nil {
+		t.Error("expect err after max attempts")
+	}
+}
@@ -0,0 +1,422 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/xiexianbin/golib/logger"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+const (
+	stateJSONFile = "git-mirrors-state.json"
+	stateKVFile   = "git-mirrors-state.db"
+
+	// stateLockTimeout is the max wait for the lock of kv state store, which is held by another run
+	stateLockTimeout = 10 * time.Second
+)
+
+// stateKVBucket is the bucket of states in kv state store
+var stateKVBucket = []byte("states")
+
+// RepoState is the sync history of a source repo to a destination repo, kept between runs
+type RepoState struct {
+	SrcFullName         string            `json:"src_full_name"`
+	DstFullName         string            `json:"dst_full_name"`
+	LastStatus          RepoStatus        `json:"last_status"`
+	LastError           string            `json:"last_error,omitempty"`
+	LastAttempt         time.Time         `json:"last_attempt"`
+	LastSuccess         time.Time         `json:"last_success"` // zero if never succeeded
+	ConsecutiveFailures int               `json:"consecutive_failures"`
+	SrcRefs             map[string]string `json:"src_refs,omitempty"` // the refs of source listed at last success
+	DstRefs             map[string]string `json:"dst_refs,omitempty"` // the refs of destination listed at last success
+}
+
+// stateKey return the key of repo state, a source repo may be mirrored to many destinations
+func stateKey(srcFullName, dstFullName string) string {
+	return srcFullName + " => " + dstFullName
+}
+
+// update record the outcome of a mirror, the skipped repos are not recorded
+func (s *RepoState) update(repo *RepoResult, now time.Time) {
+	s.SrcFullName, s.DstFullName = repo.SrcFullName, repo.DstFullName
+	s.LastStatus, s.LastAttempt = repo.Status, now
+	if repo.Status == RepoFailed {
+		s.ConsecutiveFailures += 1
+		if repo.Err != nil {
+			s.LastError = repo.Err.Error()
+		}
+		return
+	}
+
+	s.LastError, s.LastSuccess, s.ConsecutiveFailures = "", now, 0
+	s.SrcRefs, s.DstRefs = repo.srcRefs, repo.dstRefs
+}
+
+// before return true if the repo of s should be mirrored before the repo of other:
+// the failed repos first, more failures first, then the stale repos, which last success is older.
+// nil is a repo never mirrored, it is after the failed repos and before the succeeded repos
+func (s *RepoState) before(other *RepoState) bool {
+	if s == nil {
+		s = &RepoState{}
+	}
+	if other == nil {
+		other = &RepoState{}
+	}
+	if s.ConsecutiveFailures != other.ConsecutiveFailures {
+		return s.ConsecutiveFailures > other.ConsecutiveFailures
+	}
+	return s.LastSuccess.Before(other.LastSuccess)
+}
+
+// StateStore persist the RepoState of repos between runs, it is safe for concurrent use
+type StateStore interface {
+	// Get return the state of key, nil if not exist
+	Get(key string) *RepoState
+	// Put save the state of key
+	Put(key string, state *RepoState) error
+	// All return the states of all repos
+	All() map[string]*RepoState
+	// Close flush the states and release the store
+	Close() error
+}
+
+// jsonStateStore keep the states in memory and write them to a json file when closed,
+// the file is small and human-readable, but the states of a crashed run are lost
+type jsonStateStore struct {
+	mu     sync.Mutex
+	path   string
+	states map[string]*RepoState
+}
+
+func newJSONStateStore(path string) (*jsonStateStore, error) {
+	states, err := readJSONStates(path)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonStateStore{path: path, states: states}, nil
+}
+
+// readJSONStates read the states of json file, empty if the file not exist
+func readJSONStates(path string) (map[string]*RepoState, error) {
+	states := make(map[string]*RepoState)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return states, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read state %s err: %s", path, err.Error())
+	}
+	if err := json.Unmarshal(content, &states); err != nil {
+		return nil, fmt.Errorf("decode state %s err: %s", path, err.Error())
+	}
+
+	return states, nil
+}
+
+func (s *jsonStateStore) Get(key string) *RepoState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[key]
+}
+
+func (s *jsonStateStore) Put(key string, state *RepoState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[key] = state
+	return nil
+}
+
+func (s *jsonStateStore) All() map[string]*RepoState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make(map[string]*RepoState, len(s.states))
+	for key, state := range s.states {
+		states[key] = state
+	}
+	return states
+}
+
+func (s *jsonStateStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, err := json.MarshalIndent(s.states, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, content)
+}
+
+// kvStateStore is an embedded key-value store of bbolt, every Put is a transaction committed and synced to
+// the file, so the states of a crashed run are kept, and the writes of large orgs are not O(n^2) as
+// jsonStateStore. the file is locked while it is opened
+type kvStateStore struct {
+	db *bolt.DB
+}
+
+func newKVStateStore(path string) (*kvStateStore, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: stateLockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("open state %s err: %s", path, err.Error())
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(stateKVBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("open state %s err: %s", path, err.Error())
+	}
+
+	return &kvStateStore{db: db}, nil
+}
+
+// readKVStates read all the states of kvStateStore in a read-only transaction, empty if the file not exist
+func readKVStates(path string) (map[string]*RepoState, error) {
+	states := make(map[string]*RepoState)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return states, nil
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: stateLockTimeout, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("read state %s err: %s", path, err.Error())
+	}
+	defer db.Close()
+
+	s := &kvStateStore{db: db}
+	if err := s.forEach(func(key string, state *RepoState) { states[key] = state }); err != nil {
+		return nil, fmt.Errorf("read state %s err: %s", path, err.Error())
+	}
+	return states, nil
+}
+
+// forEach call fn with every state, the broken state is dropped
+func (s *kvStateStore) forEach(fn func(key string, state *RepoState)) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(stateKVBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			state := &RepoState{}
+			if err := json.Unmarshal(v, state); err != nil {
+				logger.Warnf("drop broken state %s: %s", k, err.Error())
+				return nil
+			}
+			fn(string(k), state)
+			return nil
+		})
+	})
+}
+
+func (s *kvStateStore) Get(key string) *RepoState {
+	var state *RepoState
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(stateKVBucket).Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		state = &RepoState{}
+		return json.Unmarshal(v, state)
+	})
+	if err != nil {
+		logger.Warnf("get state %s err: %s", key, err.Error())
+		return nil
+	}
+	return state
+}
+
+func (s *kvStateStore) Put(key string, state *RepoState) error {
+	v, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(stateKVBucket).Put([]byte(key), v)
+	})
+	if err != nil {
+		return fmt.Errorf("write state %s err: %s", s.db.Path(), err.Error())
+	}
+	return nil
+}
+
+func (s *kvStateStore) All() map[string]*RepoState {
+	states := make(map[string]*RepoState)
+	if err := s.forEach(func(key string, state *RepoState) { states[key] = state }); err != nil {
+		logger.Warnf("read state %s err: %s", s.db.Path(), err.Error())
+	}
+	return states
+}
+
+func (s *kvStateStore) Close() error {
+	return s.db.Close()
+}
+
+// writeFileAtomic write content to a temp file and rename it to path, so the file is never partial written
+func writeFileAtomic(path string, content []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write state %s err: %s", path, err.Error())
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(content); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("write state %s err: %s", path, err.Error())
+	}
+	return nil
+}
+
+// sharedStateStore is a StateStore opened by many mirrors, like the parallel jobs with the same cache path,
+// the store is closed when the last one closes it
+type sharedStateStore struct {
+	StateStore
+	key  string
+	refs int
+}
+
+var (
+	stateStoresMu sync.Mutex
+	stateStores   = make(map[string]*sharedStateStore)
+)
+
+func (s *sharedStateStore) Close() error {
+	stateStoresMu.Lock()
+	defer stateStoresMu.Unlock()
+	s.refs -= 1
+	if s.refs > 0 {
+		return nil
+	}
+	delete(stateStores, s.key)
+	return s.StateStore.Close()
+}
+
+// statePath return the absolute path of state store file of kind in dir, empty for none
+func statePath(kind, dir string) (string, error) {
+	switch kind {
+	case constants.StateStoreNone, "":
+		return "", nil
+	case constants.StateStoreJSON:
+		return filepath.Abs(filepath.Join(dir, stateJSONFile))
+	case constants.StateStoreKV:
+		return filepath.Abs(filepath.Join(dir, stateKVFile))
+	default:
+		return "", fmt.Errorf("un-support state-store %s", kind)
+	}
+}
+
+// OpenStateStore open the state store of kind in dir: json, kv, or none which returns nil store
+func OpenStateStore(kind, dir string) (StateStore, error) {
+	path, err := statePath(kind, dir)
+	if err != nil || path == "" {
+		return nil, err
+	}
+
+	stateStoresMu.Lock()
+	defer stateStoresMu.Unlock()
+	if s, ok := stateStores[path]; ok {
+		s.refs += 1
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create state dir %s err: %s", dir, err.Error())
+	}
+	var store StateStore
+	if kind == constants.StateStoreJSON {
+		store, err = newJSONStateStore(path)
+	} else {
+		store, err = newKVStateStore(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &sharedStateStore{StateStore: store, key: path, refs: 1}
+	stateStores[path] = s
+
+	return s, nil
+}
+
+// ReadStates read the states of state store of kind in dir without writing it, empty if the store not exist.
+// the kv store locked by the running mirror of another process can not be read until it is closed
+func ReadStates(kind, dir string) (map[string]*RepoState, error) {
+	path, err := statePath(kind, dir)
+	if err != nil || path == "" {
+		return nil, err
+	}
+	if kind == constants.StateStoreJSON {
+		return readJSONStates(path)
+	}
+
+	// the store opened by this process is locked, read it directly
+	stateStoresMu.Lock()
+	s, ok := stateStores[path]
+	stateStoresMu.Unlock()
+	if ok {
+		return s.All(), nil
+	}
+	return readKVStates(path)
+}
+
+// PrintStates print the sync history of repos as a table, the failed and stale repos first
+func PrintStates(w io.Writer, states map[string]*RepoState) error {
+	keys := make([]string, 0, len(states))
+	for key := range states {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := states[keys[i]], states[keys[j]]
+		if a.before(b) || b.before(a) {
+			return a.before(b)
+		}
+		return keys[i] < keys[j]
+	})
+
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Local().Format(time.RFC3339)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPO\tSTATUS\tLAST ATTEMPT\tLAST SUCCESS\tFAILURES\tREFS\tERROR")
+	for _, key := range keys {
+		s := states[key]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", key, s.LastStatus, formatTime(s.LastAttempt),
+			formatTime(s.LastSuccess), s.ConsecutiveFailures, len(s.SrcRefs), s.LastError)
+	}
+	return tw.Flush()
+}
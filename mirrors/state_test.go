@@ -0,0 +1,169 @@
+// Copyright 2022 xiexianbin<me@xiexianbin.cn>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirrors
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
+func TestOpenStateStore(t *testing.T) {
+	for _, kind := range []string{constants.StateStoreJSON, constants.StateStoreKV} {
+		t.Run(kind, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := OpenStateStore(kind, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the mirrors with same cache path share the store
+			s2, err := OpenStateStore(kind, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Put("a", &RepoState{ConsecutiveFailures: 1}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s2.Put("a", &RepoState{ConsecutiveFailures: 2}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Put("b", &RepoState{LastStatus: RepoSuccess}); err != nil {
+				t.Fatal(err)
+			}
+			if s2.Get("a").ConsecutiveFailures != 2 || s.Get("c") != nil {
+				t.Errorf("unexpected states %v", s.All())
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := s2.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			states, err := ReadStates(kind, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(states) != 2 || states["a"].ConsecutiveFailures != 2 || states["b"].LastStatus != RepoSuccess {
+				t.Errorf("unexpected states %v", states)
+			}
+		})
+	}
+
+	if s, err := OpenStateStore(constants.StateStoreNone, t.TempDir()); s != nil || err != nil {
+		t.Errorf("expect nil store, got %v, %v", s, err)
+	}
+	if _, err := OpenStateStore("sqlite", t.TempDir()); err == nil {
+		t.Error("expect un-support state store err")
+	}
+}
+
+func TestKVStateStore_Locked(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenStateStore(constants.StateStoreKV, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("a", &RepoState{ConsecutiveFailures: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the store opened by this process is read without the file lock
+	states, err := ReadStates(constants.StateStoreKV, dir)
+	if err != nil || len(states) != 1 || states["a"].ConsecutiveFailures != 1 {
+		t.Errorf("unexpected states %v, %v", states, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file which is not a kv store is refused
+	if err := os.WriteFile(filepath.Join(dir, stateKVFile), []byte(`{"key":"a"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenStateStore(constants.StateStoreKV, dir); err == nil {
+		t.Error("expect invalid state store err")
+	}
+}
+
+func TestRepoState_Before(t *testing.T) {
+	now := time.Now()
+	failed := &RepoState{ConsecutiveFailures: 2, LastSuccess: now}
+	stale := &RepoState{LastSuccess: now.Add(-time.Hour)}
+	fresh := &RepoState{LastSuccess: now}
+
+	ordered := []*RepoState{failed, nil, stale, fresh}
+	for i := 0; i < len(ordered)-1; i++ {
+		if !ordered[i].before(ordered[i+1]) || ordered[i+1].before(ordered[i]) {
+			t.Errorf("expect state %d before %d", i, i+1)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := PrintStates(&buf, map[string]*RepoState{"fresh": fresh, "failed": failed}); err != nil {
+		t.Fatal(err)
+	}
+	if i, j := strings.Index(buf.String(), "failed"), strings.Index(buf.String(), "fresh"); i < 0 || j < i {
+		t.Errorf("expect failed repo is printed first, got:\n%s", buf.String())
+	}
+}
+
+func TestMirror_Do_StateStore(t *testing.T) {
+	tmp := t.TempDir()
+	m := newTestMirror(t, tmp, "repo1", "repo2")
+	m.StateStore = constants.StateStoreKV
+	if _, err := m.Do(); err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := ReadStates(constants.StateStoreKV, m.CachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := stateKey(m.srcFullName("repo2"), m.dstFullName("repo2"))
+	if s := states[key]; s == nil || s.LastStatus != RepoSuccess || s.LastSuccess.IsZero() ||
+		len(s.SrcRefs) == 0 || len(s.DstRefs) == 0 {
+		t.Fatalf("unexpected state of %s: %+v", key, s)
+	}
+
+	// repo2 is failed, it is mirrored first in the next run
+	if err := os.RemoveAll(filepath.Join(tmp, "src", "repo2")); err != nil {
+		t.Fatal(err)
+	}
+	result, err := m.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failed != 1 || result.UpToDate != 1 {
+		t.Fatalf("unexpected result %s", result)
+	}
+	states, _ = ReadStates(constants.StateStoreKV, m.CachePath)
+	if s := states[key]; s.LastStatus != RepoFailed || s.ConsecutiveFailures != 1 || s.LastError == "" || s.LastSuccess.IsZero() {
+		t.Errorf("unexpected state of %s: %+v", key, s)
+	}
+
+	m.state, _ = OpenStateStore(m.StateStore, m.CachePath)
+	defer m.state.Close()
+	jobs, _ := m.jobs()
+	m.prioritize(jobs)
+	if *jobs[0].srcRepo.Name != "repo2" {
+		t.Errorf("expect failed repo2 is mirrored first, got %s", *jobs[0].srcRepo.Name)
+	}
+}
@@ -14,6 +14,14 @@
 
 package mirrors
 
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/x-actions/git-mirrors/constants"
+)
+
 func RemoveDuplicates(strs []string) []string {
 	keys := make(map[string]struct{}, len(strs))
 	d := 0
@@ -66,10 +74,11 @@ func StringsEqual(a, b *string) bool {
 	return false
 }
 
+// GitURL return the clone url from the API response, ssh for key auth, else https. the anonymous clone
+// uses https too, the git:// of github is disabled since 2022 and the ssh of others needs a key
 func GitURL(repository *Repository, authType GitAuthType) string {
 	switch authType {
-	case GitAccessTokenAuth:
-	case GitUsernamePasswordAuth:
+	case GitAccessTokenAuth, GitUsernamePasswordAuth, GitNoneAuth:
 		return *repository.CloneURL
 	case GitKeyAuth:
 		return *repository.SSHURL
@@ -77,3 +86,55 @@ func GitURL(repository *Repository, authType GitAuthType) string {
 
 	return *repository.GitURL
 }
+
+// packSize return the total size of packfiles in the bare repository path, 0 if path is not exist.
+// it only reads the directory `objects/pack`, the loose objects are not counted
+func packSize(path string) int64 {
+	entries, err := os.ReadDir(filepath.Join(path, "objects", "pack"))
+	if err != nil {
+		return 0
+	}
+
+	var size int64
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+	}
+
+	return size
+}
+
+// unsupportedRepoFields are the metadata fields which the git service can not store, they are never
+// synced, or the dst repo would be different from src repo and updated on every run. the nil topics of
+// the services which can not report them are unknown, and never compared either
+var unsupportedRepoFields = map[string]map[string]bool{
+	// gitlab project has no homepage
+	constants.GITLAB: {"homepage": true},
+	// bitbucket cloud has website, but no topics
+	constants.BITBUCKET: {"topics": true},
+	// bitbucket data center has neither homepage nor topics
+	constants.BITBUCKETSERVER: {"homepage": true, "topics": true},
+}
+
+// updatedFields return the metadata fields of dst repo which are different from src repo, the fields
+// which dstGit can not store are ignored
+func updatedFields(dstRepo, srcRepo *Repository, dstGit string) []string {
+	unsupported := unsupportedRepoFields[dstGit]
+	var fields []string
+	if !StringsEqual(dstRepo.Description, srcRepo.Description) {
+		fields = append(fields, "description")
+	}
+	if !unsupported["homepage"] && !StringsEqual(dstRepo.Homepage, srcRepo.Homepage) {
+		fields = append(fields, "homepage")
+	}
+	if !unsupported["topics"] && dstRepo.Topics != nil && srcRepo.Topics != nil &&
+		strings.Join(dstRepo.Topics, ",") != strings.Join(srcRepo.Topics, ",") {
+		fields = append(fields, "topics")
+	}
+	if dstRepo.Private != nil && srcRepo.Private != nil && *dstRepo.Private != *srcRepo.Private {
+		fields = append(fields, "private")
+	}
+
+	return fields
+}
@@ -15,9 +15,14 @@
 package mirrors
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-github/github"
+
+	"github.com/x-actions/git-mirrors/constants"
 )
 
 func TestRemoveDuplicates(t *testing.T) {
@@ -36,3 +41,78 @@ func TestReposToMap(t *testing.T) {
 	result := ReposToMap(repos)
 	t.Logf("%#v", *result[name].Name)
 }
+
+func TestUpdatedFields(t *testing.T) {
+	srcRepo := &Repository{Description: github.String("desc"), Homepage: github.String("https://www.xiexianbin.cn"),
+		Topics: []string{"mirror"}}
+	// the repo of bitbucket data center has no homepage and topics
+	dstRepo := &Repository{Description: github.String("desc"), Homepage: github.String(""), Topics: []string{}}
+
+	cases := map[string]string{
+		constants.GITEE:           "homepage,topics",
+		constants.GITLAB:          "topics",
+		constants.BITBUCKET:       "homepage",
+		constants.BITBUCKETSERVER: "",
+	}
+	for git, expected := range cases {
+		if fields := strings.Join(updatedFields(dstRepo, srcRepo, git), ","); fields != expected {
+			t.Errorf("expect updated fields of %s are %q, got %q", git, expected, fields)
+		}
+		if changed := repoInfoChanged(dstRepo, srcRepo, git); changed != (expected != "") {
+			t.Errorf("expect repo info of %s changed %v, got %v", git, expected != "", changed)
+		}
+	}
+
+	// the unknown topics are not compared
+	dstRepo.Topics = nil
+	if fields := updatedFields(dstRepo, srcRepo, constants.GITHUB); strings.Join(fields, ",") != "homepage" {
+		t.Errorf("expect unknown topics are not updated, got %v", fields)
+	}
+}
+
+func TestGitURL(t *testing.T) {
+	repo := &Repository{
+		CloneURL: github.String("https://github.com/x-actions/git-mirrors.git"),
+		GitURL:   github.String("git://github.com/x-actions/git-mirrors.git"),
+		SSHURL:   github.String("git@github.com:x-actions/git-mirrors.git"),
+	}
+	cases := map[GitAuthType]string{
+		GitKeyAuth:              *repo.SSHURL,
+		GitAccessTokenAuth:      *repo.CloneURL,
+		GitUsernamePasswordAuth: *repo.CloneURL,
+		GitNoneAuth:             *repo.CloneURL,
+	}
+	for authType, expected := range cases {
+		if url := GitURL(repo, authType); url != expected {
+			t.Errorf("expect url %s of auth type %d, got %s", expected, authType, url)
+		}
+	}
+}
+
+func TestPackSize(t *testing.T) {
+	tmp := t.TempDir()
+	if size := packSize(filepath.Join(tmp, "not-exist")); size != 0 {
+		t.Errorf("expect size 0 of not exist repo, got %d", size)
+	}
+
+	packPath := filepath.Join(tmp, "objects", "pack")
+	if err := os.MkdirAll(filepath.Join(packPath, "tmp"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(packPath, "pack-1.pack"), make([]byte, 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(packPath, "pack-1.idx"), make([]byte, 20), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// the loose objects are not counted
+	if err := os.MkdirAll(filepath.Join(tmp, "objects", "ab"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "objects", "ab", "cdef"), make([]byte, 50), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if size := packSize(tmp); size != 120 {
+		t.Errorf("expect size 120 of packs, got %d", size)
+	}
+}